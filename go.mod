@@ -1,4 +1,4 @@
-module procon2-driver
+module github.com/dalmatheo/procon2-driver
 
 go 1.26.1
 
@@ -0,0 +1,285 @@
+package procon2
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AssignPolicy decides what happens when a controller is assigned a player slot
+// another controller uses
+type AssignPolicy int
+
+const (
+	AssignFail AssignPolicy = iota // Refuse, the controller keeps its slot
+	AssignSwap                     // Move the other controller out of the way
+)
+
+// ParseAssignPolicy converts a policy name as used on the command line
+func ParseAssignPolicy(name string) (AssignPolicy, error) {
+	switch name {
+	case "fail":
+		return AssignFail, nil
+	case "swap":
+		return AssignSwap, nil
+	}
+	return AssignFail, fmt.Errorf("unknown assign policy %q (expected fail or swap)", name)
+}
+
+// ParseAssignments parses a "SERIAL=PLAYER,..." list into player numbers keyed by serial
+func ParseAssignments(spec string) (map[string]int, error) {
+	assignments := make(map[string]int)
+	if strings.TrimSpace(spec) == "" {
+		return assignments, nil
+	}
+	for _, item := range strings.Split(spec, ",") {
+		serial, player, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || serial == "" {
+			return nil, fmt.Errorf("invalid assignment %q (expected SERIAL=PLAYER)", item)
+		}
+		n, err := strconv.Atoi(player)
+		if err != nil || n < 1 || n > MaxPlayers {
+			return nil, fmt.Errorf("invalid player in %q (expected 1 to %d)", item, MaxPlayers)
+		}
+		assignments[serial] = n
+	}
+	return assignments, nil
+}
+
+// LoadSlots reads a file written by SaveSlots. A missing file is an empty map.
+func LoadSlots(path string) (map[string]int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]int), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	players, err := ParseAssignments(strings.Join(strings.Fields(string(data)), ","))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return players, nil
+}
+
+// SaveSlots writes one SERIAL=PLAYER line per controller, replacing the file atomically
+func SaveSlots(path string, players map[string]int) error {
+	serials := make([]string, 0, len(players))
+	for serial := range players {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+	var b strings.Builder
+	for _, serial := range serials {
+		fmt.Fprintf(&b, "%s=%d\n", serial, players[serial])
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadSlots reads the player numbers remembered in Config.SlotsFile
+func (m *Manager) loadSlots() {
+	m.remembered = make(map[string]int)
+	if m.cfg.SlotsFile == "" {
+		return
+	}
+	players, err := LoadSlots(m.cfg.SlotsFile)
+	if err != nil {
+		log.Printf("⚠️ Ignoring slots file: %v", err)
+		return
+	}
+	m.remembered = players
+}
+
+// rememberSlot records the slot of serial and saves Config.SlotsFile.
+// Must be called with m.mu held.
+func (m *Manager) rememberSlot(serial string, slot int) {
+	if m.cfg.SlotsFile == "" || serial == "" || m.remembered[serial] == slot+1 {
+		return
+	}
+	m.remembered[serial] = slot + 1
+	if err := SaveSlots(m.cfg.SlotsFile, m.remembered); err != nil {
+		log.Printf("⚠️ Failed to save slots file: %v", err)
+	}
+}
+
+// rememberedSlot takes back the slot serial had last time, moving a controller that took
+// it meanwhile to a free slot. It returns -1 when there is none to take.
+// Must be called with m.mu held.
+func (m *Manager) rememberedSlot(serial string, moved *[]*ActiveDriver) int {
+	player, ok := m.remembered[serial]
+	if !ok || serial == "" {
+		return -1
+	}
+	slot := player - 1
+	if !m.slots[slot] {
+		m.slots[slot] = true
+		return slot
+	}
+
+	other := m.driverAt(slot)
+	if other == nil {
+		return -1
+	}
+	free := m.findUnclaimedSlot(other.Serial)
+	if free == -1 {
+		log.Printf("⚠️ Player %d was %s's but there is no free slot to move %s to", player, serial, other.UniqueID)
+		return -1
+	}
+	log.Printf("🔀 Player %d (%s) -> Player %d to give %s its slot back", slot+1, other.UniqueID, free+1, serial)
+	m.moveTo(other, free)
+	*moved = append(*moved, other)
+	return slot
+}
+
+// findUnclaimedSlot takes a free slot, preferring those no other serial than serial
+// remembers, and returns -1 when all are taken. Must be called with m.mu held.
+func (m *Manager) findUnclaimedSlot(serial string) int {
+	claimed := make(map[int]bool, len(m.remembered))
+	for s, player := range m.remembered {
+		if s != serial {
+			claimed[player-1] = true
+		}
+	}
+	for i := 0; i < MaxPlayers; i++ {
+		if !m.slots[i] && !claimed[i] {
+			m.slots[i] = true
+			return i
+		}
+	}
+	return m.findFreeSlot()
+}
+
+// Assign moves a running controller to a player number, from 1 to MaxPlayers.
+// If another controller has it, Config.AssignPolicy says whether they swap.
+func (m *Manager) Assign(uid string, player int) error {
+	if player < 1 || player > MaxPlayers {
+		return fmt.Errorf("invalid player %d (expected 1 to %d)", player, MaxPlayers)
+	}
+
+	m.mu.Lock()
+	ad, ok := m.drivers[uid]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("no running controller at %s", uid)
+	}
+	moved, err := m.reslot(ad, player-1)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.showSlots(moved)
+	return nil
+}
+
+// reslot moves ad to slot and returns the drivers whose slot changed.
+// Must be called with m.mu held.
+func (m *Manager) reslot(ad *ActiveDriver, slot int) ([]*ActiveDriver, error) {
+	if ad.Slot == slot {
+		return nil, nil
+	}
+	if !m.slots[slot] {
+		m.slots[ad.Slot] = false
+		m.slots[slot] = true
+		log.Printf("🔀 Player %d (%s) -> Player %d", ad.Slot+1, ad.UniqueID, slot+1)
+		m.moveTo(ad, slot)
+		return []*ActiveDriver{ad}, nil
+	}
+
+	other := m.driverAt(slot)
+	if other == nil {
+		return nil, fmt.Errorf("player %d is reserved for a controller that is connecting or reconnecting", slot+1)
+	}
+	if m.cfg.AssignPolicy != AssignSwap {
+		return nil, fmt.Errorf("player %d is used by %s", slot+1, other.UniqueID)
+	}
+	log.Printf("🔀 Player %d (%s) <-> Player %d (%s)", ad.Slot+1, ad.UniqueID, slot+1, other.UniqueID)
+	from := ad.Slot
+	m.moveTo(ad, slot)
+	m.moveTo(other, from)
+	return []*ActiveDriver{ad, other}, nil
+}
+
+// assignedSlot takes the slot configured for serial in Config.Assignments, moving its
+// current user to a free slot under AssignSwap. It returns -1 when there is none to take.
+// Must be called with m.mu held.
+func (m *Manager) assignedSlot(serial string, moved *[]*ActiveDriver) int {
+	player, ok := m.cfg.Assignments[serial]
+	if !ok || serial == "" {
+		return -1
+	}
+	slot := player - 1
+	if !m.slots[slot] {
+		m.slots[slot] = true
+		return slot
+	}
+
+	other := m.driverAt(slot)
+	if other == nil || m.cfg.AssignPolicy != AssignSwap {
+		log.Printf("⚠️ Player %d is assigned to %s but already taken", player, serial)
+		return -1
+	}
+	free := m.findUnclaimedSlot(other.Serial)
+	if free == -1 {
+		log.Printf("⚠️ Player %d is assigned to %s but there is no free slot to move %s to", player, serial, other.UniqueID)
+		return -1
+	}
+	log.Printf("🔀 Player %d (%s) -> Player %d to make room for %s", slot+1, other.UniqueID, free+1, serial)
+	m.moveTo(other, free)
+	*moved = append(*moved, other)
+	return slot
+}
+
+// moveTo changes the slot of a running driver and tells it so. Must be called with m.mu held.
+func (m *Manager) moveTo(ad *ActiveDriver, slot int) {
+	ad.Slot = slot
+	ad.Driver.log.slot.Store(int32(slot))
+	m.rememberSlot(ad.Serial, slot)
+	m.notifyReslot(ad)
+}
+
+// driverAt returns the running driver using slot, or nil. Must be called with m.mu held.
+func (m *Manager) driverAt(slot int) *ActiveDriver {
+	for _, ad := range m.drivers {
+		if ad.Slot == slot {
+			return ad
+		}
+	}
+	return nil
+}
+
+// showSlots updates the player LEDs of drivers whose slot changed
+func (m *Manager) showSlots(moved []*ActiveDriver) {
+	// Serialized since concurrent callers could write to the same controller
+	m.ledMu.Lock()
+	defer m.ledMu.Unlock()
+
+	for _, ad := range moved {
+		m.mu.Lock()
+		slot, running := ad.Slot, m.drivers[ad.UniqueID] == ad
+		m.mu.Unlock()
+		if !running {
+			continue
+		}
+
+		ctrl := ad.Driver.controller
+		if ctrl.ReadOnly() {
+			continue
+		}
+		if err := ctrl.SetLEDPattern(PlayerLEDPattern(m.cfg.PlayerLEDs, slot+1)); err != nil {
+			log.Printf("⚠️ Failed to set LEDs of Player %d: %v", slot+1, err)
+		}
+	}
+}
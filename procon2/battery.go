@@ -0,0 +1,78 @@
+package procon2
+
+import (
+	"sync"
+	"time"
+)
+
+// BatterySample is the battery status carried by one report
+type BatterySample struct {
+	Time     time.Time
+	Level    int // 0 (empty) to 8 (full)
+	Charging bool
+}
+
+// batteryOffsets gives the battery byte of report formats that carry one:
+// level in the high nibble, charging in bit 4. Where 0x09 reports keep it
+// is not known yet, so the Switch 2 Pro Controller has no battery history.
+var batteryOffsets = map[byte]int{
+	0x30: 2,
+}
+
+// parseBattery reads the battery status of a report, ok is false if it has none
+func parseBattery(report []byte, now time.Time) (BatterySample, bool) {
+	if len(report) == 0 {
+		return BatterySample{}, false
+	}
+	off, ok := batteryOffsets[report[0]]
+	if !ok || len(report) <= off {
+		return BatterySample{}, false
+	}
+	b := report[off]
+	return BatterySample{Time: now, Level: int(b >> 5 << 1), Charging: b&0x10 != 0}, true
+}
+
+// Battery history tuning
+const (
+	batteryHistorySize = 64          // Samples kept per controller
+	batterySampleEvery = time.Minute // A sample is recorded this often even when nothing changed
+)
+
+// batteryHistory keeps battery samples, recent ones at full resolution and older
+// ones thinned out each time it fills, so a long session still fits
+type batteryHistory struct {
+	mu      sync.Mutex
+	samples []BatterySample
+}
+
+// record adds s if the status changed or the last sample is old enough
+func (h *batteryHistory) record(s BatterySample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n := len(h.samples); n > 0 {
+		last := h.samples[n-1]
+		if last.Level == s.Level && last.Charging == s.Charging && s.Time.Sub(last.Time) < batterySampleEvery {
+			return
+		}
+	}
+	if len(h.samples) == batteryHistorySize {
+		// Drop every other sample of the older half
+		half := batteryHistorySize / 2
+		kept := h.samples[:0]
+		for i, old := range h.samples[:half] {
+			if i%2 == 0 {
+				kept = append(kept, old)
+			}
+		}
+		h.samples = append(kept, h.samples[half:]...)
+	}
+	h.samples = append(h.samples, s)
+}
+
+// snapshot returns a copy of the samples, oldest first
+func (h *batteryHistory) snapshot() []BatterySample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]BatterySample(nil), h.samples...)
+}
@@ -0,0 +1,147 @@
+package procon2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BindTarget is an extra code a button sends, on the device of one output backend
+type BindTarget struct {
+	Output OutputKind // OutputGamepad or OutputKeyboard
+	Code   uint16     // Linux key or button code
+}
+
+// Bindings lists extra codes buttons send on top of their usual mapping, e.g. A also
+// sending Enter. Every code goes out in the same frame as the button itself.
+type Bindings map[Button][]BindTarget
+
+// bindCodeNames are the code names accepted by ParseBindings, besides plain numbers
+var bindCodeNames = map[string]uint16{
+	"btn_south": btnSouth, "btn_a": btnSouth, "btn_east": btnEast, "btn_b": btnEast,
+	"btn_north": btnNorth, "btn_x": btnNorth, "btn_west": btnWest, "btn_y": btnWest,
+	"btn_tl": btnTL, "btn_tr": btnTR, "btn_tl2": btnTL2, "btn_tr2": btnTR2,
+	"btn_select": btnSelect, "btn_start": btnStart, "btn_mode": btnMode,
+	"btn_thumbl": btnThumbL, "btn_thumbr": btnThumbR,
+	"btn_dpad_up": btnDpadUp, "btn_dpad_down": btnDpadDown, "btn_dpad_left": btnDpadLeft, "btn_dpad_right": btnDpadRight,
+	"btn_trigger_happy1": btnTriggerHappy1,
+	"key_esc":            keyEsc, "key_backspace": keyBackspace, "key_tab": keyTab, "key_enter": keyEnter,
+	"key_leftshift": keyLeftShift, "key_space": keySpace, "key_up": keyUp, "key_down": keyDown,
+	"key_left": keyLeft, "key_right": keyRight, "key_pageup": keyPageUp, "key_pagedown": keyPageDown,
+	"key_homepage": keyHomepage, "key_sysrq": keySysrq,
+}
+
+// ParseBindings parses BUTTON=OUTPUT:CODE[+OUTPUT:CODE...] items separated by commas,
+// e.g. "a=keyboard:key_enter,zr=gamepad:btn_tr+gamepad:btn_south". OUTPUT is gamepad or
+// keyboard, CODE a Linux code name such as key_enter or btn_start, or its number.
+func ParseBindings(spec string) (Bindings, error) {
+	bindings := make(Bindings)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, targets, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid binding %q (expected BUTTON=OUTPUT:CODE)", item)
+		}
+		button, err := ParseButton(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range strings.Split(targets, "+") {
+			target, err := parseBindTarget(strings.TrimSpace(t))
+			if err != nil {
+				return nil, fmt.Errorf("binding %q: %w", item, err)
+			}
+			bindings[button] = append(bindings[button], target)
+		}
+	}
+	return bindings, nil
+}
+
+func parseBindTarget(s string) (BindTarget, error) {
+	output, code, ok := strings.Cut(s, ":")
+	if !ok {
+		return BindTarget{}, fmt.Errorf("invalid target %q (expected OUTPUT:CODE)", s)
+	}
+	kinds, err := ParseOutputKinds(output)
+	if err != nil || len(kinds) != 1 || (kinds[0] != OutputGamepad && kinds[0] != OutputKeyboard) {
+		return BindTarget{}, fmt.Errorf("invalid output %q (expected gamepad or keyboard)", output)
+	}
+	c, known := bindCodeNames[strings.ToLower(code)]
+	if !known {
+		n, err := strconv.ParseUint(code, 0, 16)
+		if err != nil || n == 0 {
+			return BindTarget{}, fmt.Errorf("unknown code %q", code)
+		}
+		c = uint16(n)
+	}
+	return BindTarget{Output: kinds[0], Code: c}, nil
+}
+
+// Uses reports whether any binding targets the given backend
+func (b Bindings) Uses(kind OutputKind) bool {
+	return len(b.codes(kind)) > 0
+}
+
+// codes returns the extra codes of the given backend, with the buttons sending each
+func (b Bindings) codes(kind OutputKind) extraCodes {
+	var extra extraCodes
+	index := make(map[uint16]int)
+	for button, targets := range b {
+		for _, t := range targets {
+			if t.Output != kind {
+				continue
+			}
+			i, ok := index[t.Code]
+			if !ok {
+				i = len(extra)
+				index[t.Code] = i
+				extra = append(extra, extraCode{code: t.Code})
+			}
+			extra[i].buttons = append(extra[i].buttons, button)
+		}
+	}
+	return extra
+}
+
+// extraCodes are the extra codes of one device, each listed once, built when the
+// device is created so sending a frame doesn't have to
+type extraCodes []extraCode
+
+// extraCode is a bound code and the buttons sending it
+type extraCode struct {
+	code    uint16
+	buttons []Button
+}
+
+// all returns every code, for advertising them when creating the device
+func (e extraCodes) all() []uint16 {
+	codes := make([]uint16, len(e))
+	for i, c := range e {
+		codes[i] = c.code
+	}
+	return codes
+}
+
+// pressed fills held with each extra code and whether one of its buttons is held, and
+// returns it. A code bound to several buttons is held while any of them is. held is
+// reused from frame to frame, it is only allocated the first time.
+func (e extraCodes) pressed(state ControllerState, held map[uint16]bool) map[uint16]bool {
+	if len(e) == 0 {
+		return nil
+	}
+	if held == nil {
+		held = make(map[uint16]bool, len(e))
+	}
+	clear(held)
+	for _, c := range e {
+		down := false
+		for _, b := range c.buttons {
+			down = down || state.Pressed(b)
+		}
+		held[c.code] = down
+	}
+	return held
+}
@@ -0,0 +1,40 @@
+package procon2
+
+import "testing"
+
+func TestExtraCodesPressed(t *testing.T) {
+	b, err := ParseBindings("a=keyboard:key_enter,b=keyboard:key_enter+keyboard:key_esc,x=gamepad:btn_start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra := b.codes(OutputKeyboard)
+	if len(extra) != 2 {
+		t.Fatalf("keyboard codes: %v, want Enter and Esc once each", extra.all())
+	}
+
+	tests := []struct {
+		name       string
+		state      ControllerState
+		enter, esc bool
+	}{
+		{"nothing held", ControllerState{}, false, false},
+		{"A holds Enter", ControllerState{A: true}, true, false},
+		{"B holds both", ControllerState{B: true}, true, true},
+		{"A and B", ControllerState{A: true, B: true}, true, true},
+		{"gamepad binding ignored", ControllerState{X: true}, false, false},
+	}
+	var held map[uint16]bool
+	for _, tt := range tests {
+		held = extra.pressed(tt.state, held)
+		if len(held) != 2 || held[keyEnter] != tt.enter || held[keyEsc] != tt.esc {
+			t.Errorf("%s: held %v, want Enter=%v Esc=%v", tt.name, held, tt.enter, tt.esc)
+		}
+		delete(held, keyEnter) // Like sendButton, the next frame must not depend on it
+	}
+
+	// Once the map exists, frames don't allocate
+	state := ControllerState{B: true}
+	if n := testing.AllocsPerRun(100, func() { held = extra.pressed(state, held) }); n != 0 {
+		t.Errorf("pressed allocates %v times per frame", n)
+	}
+}
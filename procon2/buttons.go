@@ -0,0 +1,129 @@
+package procon2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Button identifies a physical controller button
+type Button int
+
+const (
+	ButtonNone Button = iota
+	ButtonA
+	ButtonB
+	ButtonX
+	ButtonY
+	ButtonL
+	ButtonR
+	ButtonZL
+	ButtonZR
+	ButtonUp
+	ButtonDown
+	ButtonLeft
+	ButtonRight
+	ButtonPlus
+	ButtonMinus
+	ButtonHome
+	ButtonCapture
+	ButtonLStick
+	ButtonRStick
+	ButtonGL
+	ButtonGR
+)
+
+// AllButtons lists every physical button, in report order
+var AllButtons = []Button{
+	ButtonA, ButtonB, ButtonX, ButtonY,
+	ButtonL, ButtonR, ButtonZL, ButtonZR,
+	ButtonUp, ButtonDown, ButtonLeft, ButtonRight,
+	ButtonPlus, ButtonMinus, ButtonHome, ButtonCapture,
+	ButtonLStick, ButtonRStick, ButtonGL, ButtonGR,
+}
+
+// buttonNames are the names used on the command line
+var buttonNames = map[Button]string{
+	ButtonA: "A", ButtonB: "B", ButtonX: "X", ButtonY: "Y",
+	ButtonL: "L", ButtonR: "R", ButtonZL: "ZL", ButtonZR: "ZR",
+	ButtonUp: "UP", ButtonDown: "DOWN", ButtonLeft: "LEFT", ButtonRight: "RIGHT",
+	ButtonPlus: "PLUS", ButtonMinus: "MINUS", ButtonHome: "HOME", ButtonCapture: "CAPTURE",
+	ButtonLStick: "LSTICK", ButtonRStick: "RSTICK", ButtonGL: "GL", ButtonGR: "GR",
+}
+
+// ParseButton converts a button name (case insensitive) to a Button
+func ParseButton(name string) (Button, error) {
+	for b, n := range buttonNames {
+		if strings.EqualFold(n, name) {
+			return b, nil
+		}
+	}
+	return ButtonNone, fmt.Errorf("unknown button %q", name)
+}
+
+func (b Button) String() string {
+	if n, ok := buttonNames[b]; ok {
+		return n
+	}
+	return "NONE"
+}
+
+// Pressed reports whether b is held in the state
+func (s ControllerState) Pressed(b Button) bool {
+	if p := s.button(b); p != nil {
+		return *p
+	}
+	return false
+}
+
+// SetPressed changes the state of b
+func (s *ControllerState) SetPressed(b Button, pressed bool) {
+	if p := s.button(b); p != nil {
+		*p = pressed
+	}
+}
+
+func (s *ControllerState) button(b Button) *bool {
+	switch b {
+	case ButtonA:
+		return &s.A
+	case ButtonB:
+		return &s.B
+	case ButtonX:
+		return &s.X
+	case ButtonY:
+		return &s.Y
+	case ButtonL:
+		return &s.L
+	case ButtonR:
+		return &s.R
+	case ButtonZL:
+		return &s.ZL
+	case ButtonZR:
+		return &s.ZR
+	case ButtonUp:
+		return &s.DpadUp
+	case ButtonDown:
+		return &s.DpadDown
+	case ButtonLeft:
+		return &s.DpadLeft
+	case ButtonRight:
+		return &s.DpadRight
+	case ButtonPlus:
+		return &s.Plus
+	case ButtonMinus:
+		return &s.Minus
+	case ButtonHome:
+		return &s.Home
+	case ButtonCapture:
+		return &s.Capture
+	case ButtonLStick:
+		return &s.LStickPress
+	case ButtonRStick:
+		return &s.RStickPress
+	case ButtonGL:
+		return &s.PaddleLeft
+	case ButtonGR:
+		return &s.PaddleRight
+	}
+	return nil
+}
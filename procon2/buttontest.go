@@ -0,0 +1,111 @@
+package procon2
+
+import (
+	"fmt"
+	"time"
+)
+
+// ButtonTestResult is where a button was found in the reports
+type ButtonTestResult struct {
+	Button  Button
+	Found   bool // False when nothing changed before the timeout
+	Byte    int  // Report byte, counting the report ID as byte 0
+	Bit     int
+	Decoded bool // Whether the driver's parser also saw the press
+}
+
+// RunButtonTest asks for each button in turn and records which report bit it changes.
+// Bits that move on their own while idle (timer, sticks, motion) are learnt first and ignored.
+func RunButtonTest(reader *HIDReader, buttons []Button, timeout time.Duration) ([]ButtonTestResult, error) {
+	fmt.Println("Leave the controller untouched for a second...")
+	noise, err := learnReportNoise(reader, time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ButtonTestResult
+	for _, b := range buttons {
+		fmt.Printf("👉 Press and release %s (%v to skip)... ", b, timeout)
+		baseline := reader.LastRawReport()
+		res := ButtonTestResult{Button: b}
+
+		deadline := reader.clock.Now().Add(timeout)
+		for reader.clock.Now().Before(deadline) && !res.Found {
+			state, err := reader.ReadStateTimeout(100 * time.Millisecond)
+			if err != nil {
+				continue
+			}
+			rep := reader.LastRawReport()
+			for i := 0; i < len(rep) && i < len(baseline) && !res.Found; i++ {
+				changed := (rep[i] ^ baseline[i]) &^ noise[i]
+				for bit := 0; bit < 8; bit++ {
+					if changed&(1<<bit) != 0 {
+						res.Found, res.Byte, res.Bit = true, i, bit
+						res.Decoded = state.Pressed(b)
+						break
+					}
+				}
+			}
+		}
+
+		if !res.Found {
+			fmt.Println("skipped")
+		} else {
+			fmt.Printf("byte %d bit %d\n", res.Byte, res.Bit)
+			waitForRelease(reader, baseline, res, timeout)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// learnReportNoise returns, per byte, the bits that changed while the controller was idle
+func learnReportNoise(reader *HIDReader, d time.Duration) ([]byte, error) {
+	// Any report format will do, clones don't always send full reports
+	if _, err := reader.ReadStateTimeout(2 * time.Second); err != nil {
+		return nil, fmt.Errorf("no report from the controller: %w", err)
+	}
+	first := reader.LastRawReport()
+	noise := make([]byte, len(first))
+	for end := reader.clock.Now().Add(d); reader.clock.Now().Before(end); {
+		if _, err := reader.ReadStateTimeout(100 * time.Millisecond); err != nil {
+			continue
+		}
+		rep := reader.LastRawReport()
+		for i := 0; i < len(rep) && i < len(noise); i++ {
+			noise[i] |= rep[i] ^ first[i]
+		}
+	}
+	return noise, nil
+}
+
+// waitForRelease blocks until the bit found for a button is back to its idle value
+func waitForRelease(reader *HIDReader, baseline []byte, res ButtonTestResult, timeout time.Duration) {
+	mask := byte(1 << res.Bit)
+	for end := reader.clock.Now().Add(timeout); reader.clock.Now().Before(end); {
+		if _, err := reader.ReadStateTimeout(100 * time.Millisecond); err != nil {
+			continue
+		}
+		if rep := reader.LastRawReport(); res.Byte < len(rep) && (rep[res.Byte]^baseline[res.Byte])&mask == 0 {
+			return
+		}
+	}
+}
+
+// PrintButtonTest prints the results as a table to paste in a bug report
+func PrintButtonTest(results []ButtonTestResult) {
+	fmt.Println()
+	fmt.Println("Button   | Byte | Bit | Decoded")
+	fmt.Println("---------|------|-----|--------")
+	for _, r := range results {
+		if !r.Found {
+			fmt.Printf("%-8s |    - |   - | not pressed\n", r.Button)
+			continue
+		}
+		decoded := "yes"
+		if !r.Decoded {
+			decoded = "NO"
+		}
+		fmt.Printf("%-8s | %4d | %3d | %s\n", r.Button, r.Byte, r.Bit, decoded)
+	}
+}
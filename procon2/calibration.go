@@ -0,0 +1,642 @@
+package procon2
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CalibrationTuning sets what a calibration adds to the measured values
+type CalibrationTuning struct {
+	Deadzone int // Minimum stick deadzone, raised for sticks that jitter more at rest
+	Margin   int // Added beyond the measured extremes so the edges stay reachable
+	// CenterTolerance is how far, as a fraction of half the range, a center may sit from
+	// the middle of its range before it is reported as captured off-center. Zero disables the check.
+	CenterTolerance float64
+}
+
+// DefaultCalibrationTuning matches what calibrations always used before it was configurable
+var DefaultCalibrationTuning = CalibrationTuning{Deadzone: 50, Margin: 100, CenterTolerance: 0.2}
+
+// CheckCalibrationCenter returns a description of every axis whose center is further than
+// tolerance, as a fraction of half its range, from the middle of its range. It usually
+// means a stick was touched while its center was measured.
+func CheckCalibrationCenter(cal JoystickCalibration, tolerance float64) []string {
+	if tolerance <= 0 {
+		return nil
+	}
+	axes := []struct {
+		name           string
+		center, lo, hi int
+	}{
+		{"left X", cal.LXCenter, cal.LXMin, cal.LXMax},
+		{"left Y", cal.LYCenter, cal.LYMin, cal.LYMax},
+		{"right X", cal.RXCenter, cal.RXMin, cal.RXMax},
+		{"right Y", cal.RYCenter, cal.RYMin, cal.RYMax},
+	}
+	var off []string
+	for _, a := range axes {
+		half := float64(a.hi-a.lo) / 2
+		if half <= 0 {
+			continue
+		}
+		mid := float64(a.lo+a.hi) / 2
+		if d := math.Abs(float64(a.center)-mid) / half; d > tolerance {
+			off = append(off, fmt.Sprintf("%s center %d is %.0f%% of the range away from its middle %.0f",
+				a.name, a.center, d*100, mid))
+		}
+	}
+	return off
+}
+
+// centerMeasurement is the averaged resting position of both sticks and how much they wandered
+type centerMeasurement struct {
+	lx, ly, rx, ry   int
+	lJitter, rJitter stickJitter
+}
+
+// measureCenter averages samples reports taken interval apart
+func measureCenter(reader *HIDReader, samples int, interval time.Duration) (centerMeasurement, error) {
+	var m centerMeasurement
+	lxSum, lySum, rxSum, rySum := 0, 0, 0, 0
+	for i := 0; i < samples; i++ {
+		// Get raw values directly from HID data
+		lx, ly, rx, ry, err := readRawStickValues(reader)
+		if err != nil {
+			return m, err
+		}
+		m.lJitter.add(lx, ly)
+		m.rJitter.add(rx, ry)
+
+		lxSum += lx
+		lySum += ly
+		rxSum += rx
+		rySum += ry
+
+		reader.clock.Sleep(interval)
+	}
+	m.lx, m.ly = lxSum/samples, lySum/samples
+	m.rx, m.ry = rxSum/samples, rySum/samples
+	return m, nil
+}
+
+// apply stores the centers and the deadzones covering the jitter in cal
+func (m centerMeasurement) apply(cal *JoystickCalibration, tuning CalibrationTuning) {
+	cal.LXCenter, cal.LYCenter = m.lx, m.ly
+	cal.RXCenter, cal.RYCenter = m.rx, m.ry
+	cal.LDeadzone, cal.RDeadzone = tuning.deadzone(m.lJitter), tuning.deadzone(m.rJitter)
+}
+
+// stickJitter tracks how far a resting stick wanders
+type stickJitter struct {
+	minX, maxX, minY, maxY int
+	seen                   bool
+}
+
+func (j *stickJitter) add(x, y int) {
+	if !j.seen {
+		j.minX, j.maxX, j.minY, j.maxY, j.seen = x, x, y, y, true
+		return
+	}
+	j.minX, j.maxX = minInt(j.minX, x), maxInt(j.maxX, x)
+	j.minY, j.maxY = minInt(j.minY, y), maxInt(j.maxY, y)
+}
+
+// spread is the largest peak to peak wander of both axes
+func (j stickJitter) spread() int {
+	return maxInt(j.maxX-j.minX, j.maxY-j.minY)
+}
+
+// deadzone returns a deadzone covering the jitter of a stick, at least t.Deadzone.
+// The full peak to peak spread is used so the noise around the center never leaks through.
+func (t CalibrationTuning) deadzone(j stickJitter) int {
+	return maxInt(t.Deadzone, j.spread())
+}
+
+// CalibrateJoysticks performs an interactive calibration process
+// Returns a new JoystickCalibration with measured values
+func CalibrateJoysticks(reader *HIDReader) (JoystickCalibration, error) {
+	return CalibrateJoysticksWithTuning(reader, DefaultCalibrationTuning)
+}
+
+// CalibrateJoysticksWithTuning is CalibrateJoysticks with a chosen deadzone and margin
+func CalibrateJoysticksWithTuning(reader *HIDReader, tuning CalibrationTuning) (JoystickCalibration, error) {
+	cal := JoystickCalibration{
+		Deadzone: tuning.Deadzone,
+	}
+
+	fmt.Println("🎮 Joystick Calibration Wizard")
+	fmt.Println("===============================")
+	fmt.Println()
+
+	// Step 1: Center position
+	fmt.Println("Step 1: CENTER POSITION")
+	fmt.Println("➜ Let both sticks rest in their natural center position")
+	fmt.Println("➜ Don't touch the sticks!")
+	fmt.Print("\nPress ENTER when ready...")
+	fmt.Scanln()
+
+	centerSamples := 50
+	fmt.Printf("Collecting %d samples...\n", centerSamples)
+
+	center, err := measureCenter(reader, centerSamples, 20*time.Millisecond)
+	if err != nil {
+		return cal, err
+	}
+	center.apply(&cal, tuning)
+
+	fmt.Printf("✅ Center values recorded:\n")
+	fmt.Printf("   Left:  X=%d Y=%d\n", cal.LXCenter, cal.LYCenter)
+	fmt.Printf("   Right: X=%d Y=%d\n", cal.RXCenter, cal.RYCenter)
+	fmt.Printf("   Jitter: Left=%d Right=%d\n\n", center.lJitter.spread(), center.rJitter.spread())
+
+	// Step 2: Full range motion
+	fmt.Println("Step 2: FULL RANGE")
+	fmt.Println("➜ Move BOTH sticks in complete circles")
+	fmt.Println("➜ Push them all the way to the edges in ALL directions")
+	fmt.Println("➜ Continue for about 5 seconds")
+	fmt.Print("\nPress ENTER to start...")
+	fmt.Scanln()
+
+	duration := 5 * time.Second
+	fmt.Printf("Recording for %v... GO!\n", duration)
+
+	lxMin, lyMin, rxMin, ryMin := 4095, 4095, 4095, 4095
+	lxMax, lyMax, rxMax, ryMax := 0, 0, 0, 0
+
+	startTime := reader.clock.Now()
+	sampleCount := 0
+
+	for reader.clock.Now().Sub(startTime) < duration {
+		lx, ly, rx, ry, err := readRawStickValues(reader)
+		if err != nil {
+			continue
+		}
+
+		// Track min/max
+		if lx < lxMin {
+			lxMin = lx
+		}
+		if lx > lxMax {
+			lxMax = lx
+		}
+		if ly < lyMin {
+			lyMin = ly
+		}
+		if ly > lyMax {
+			lyMax = ly
+		}
+		if rx < rxMin {
+			rxMin = rx
+		}
+		if rx > rxMax {
+			rxMax = rx
+		}
+		if ry < ryMin {
+			ryMin = ry
+		}
+		if ry > ryMax {
+			ryMax = ry
+		}
+
+		sampleCount++
+
+		// Progress indicator
+		if sampleCount%20 == 0 {
+			elapsed := reader.clock.Now().Sub(startTime)
+			remaining := duration - elapsed
+			fmt.Printf("\rRecording... %.1fs remaining", remaining.Seconds())
+		}
+
+		reader.clock.Sleep(20 * time.Millisecond)
+	}
+
+	fmt.Printf("\r✅ Range calibration complete! (%d samples)\n\n", sampleCount)
+
+	// Set calibration values with some margin
+	margin := tuning.Margin
+	cal.LXMin = maxInt(lxMin-margin, 0)
+	cal.LXMax = minInt(lxMax+margin, 4095)
+	cal.LYMin = maxInt(lyMin-margin, 0)
+	cal.LYMax = minInt(lyMax+margin, 4095)
+	cal.RXMin = maxInt(rxMin-margin, 0)
+	cal.RXMax = minInt(rxMax+margin, 4095)
+	cal.RYMin = maxInt(ryMin-margin, 0)
+	cal.RYMax = minInt(ryMax+margin, 4095)
+
+	// A stick held during step 1 shows as a center far from the middle of its range
+	for off := CheckCalibrationCenter(cal, tuning.CenterTolerance); len(off) > 0; off = CheckCalibrationCenter(cal, tuning.CenterTolerance) {
+		fmt.Println("⚠️ The centers look off, was a stick touched during step 1?")
+		for _, o := range off {
+			fmt.Printf("   %s\n", o)
+		}
+		fmt.Print("Redo the center step? (y/n): ")
+		var response string
+		fmt.Scanln(&response)
+		if !strings.HasPrefix(strings.ToLower(response), "y") {
+			break
+		}
+		fmt.Print("➜ Let both sticks rest, then press ENTER...")
+		fmt.Scanln()
+		center, err := measureCenter(reader, centerSamples, 20*time.Millisecond)
+		if err != nil {
+			return cal, err
+		}
+		center.apply(&cal, tuning)
+		fmt.Printf("✅ Center re-recorded: Left X=%d Y=%d, Right X=%d Y=%d\n\n",
+			cal.LXCenter, cal.LYCenter, cal.RXCenter, cal.RYCenter)
+	}
+
+	// Display results
+	fmt.Println("📊 Calibration Results:")
+	fmt.Println("========================")
+	fmt.Printf("\nLeft Stick:\n")
+	fmt.Printf("  X: Center=%d, Min=%d, Max=%d (Range: %d)\n",
+		cal.LXCenter, cal.LXMin, cal.LXMax, cal.LXMax-cal.LXMin)
+	fmt.Printf("  Y: Center=%d, Min=%d, Max=%d (Range: %d)\n",
+		cal.LYCenter, cal.LYMin, cal.LYMax, cal.LYMax-cal.LYMin)
+
+	fmt.Printf("\nRight Stick:\n")
+	fmt.Printf("  X: Center=%d, Min=%d, Max=%d (Range: %d)\n",
+		cal.RXCenter, cal.RXMin, cal.RXMax, cal.RXMax-cal.RXMin)
+	fmt.Printf("  Y: Center=%d, Min=%d, Max=%d (Range: %d)\n",
+		cal.RYCenter, cal.RYMin, cal.RYMax, cal.RYMax-cal.RYMin)
+
+	fmt.Printf("\nDeadzone: Left=%d Right=%d\n\n", cal.LDeadzone, cal.RDeadzone)
+
+	// Generate code output
+	fmt.Println("📋 Copy this calibration to your code:")
+	fmt.Println("=======================================")
+	fmt.Printf(`var MyCalibration = JoystickCalibration{
+	LXCenter: %d, LXMin: %d, LXMax: %d,
+	LYCenter: %d, LYMin: %d, LYMax: %d,
+	RXCenter: %d, RXMin: %d, RXMax: %d,
+	RYCenter: %d, RYMin: %d, RYMax: %d,
+	Deadzone: %d, LDeadzone: %d, RDeadzone: %d,
+	LXTrim: %d, LYTrim: %d, RXTrim: %d, RYTrim: %d,
+}
+`, cal.LXCenter, cal.LXMin, cal.LXMax,
+		cal.LYCenter, cal.LYMin, cal.LYMax,
+		cal.RXCenter, cal.RXMin, cal.RXMax,
+		cal.RYCenter, cal.RYMin, cal.RYMax,
+		cal.Deadzone, cal.LDeadzone, cal.RDeadzone,
+		cal.LXTrim, cal.LYTrim, cal.RXTrim, cal.RYTrim)
+
+	return cal, nil
+}
+
+// readRawStickValues reads raw 12-bit joystick values from HID report
+func readRawStickValues(reader *HIDReader) (lx, ly, rx, ry int, err error) {
+	// Read a fresh report
+	n, readErr := reader.file.Read(reader.buffer[:])
+	if readErr != nil {
+		return 0, 0, 0, 0, fmt.Errorf("read error: %w", readErr)
+	}
+
+	data := stripReportPrefix(reader.buffer[:n])
+	if len(data) < 12 {
+		return 0, 0, 0, 0, fmt.Errorf("report too short: %d bytes", len(data))
+	}
+
+	reportID := data[0]
+
+	// Extract raw stick values using the existing function
+	lx, ly, lOK := getStickValues(data, true, reportID)
+	rx, ry, rOK := getStickValues(data, false, reportID)
+
+	if !lOK || !rOK {
+		return 0, 0, 0, 0, fmt.Errorf("invalid stick values")
+	}
+
+	return lx, ly, rx, ry, nil
+}
+
+// TestCalibration shows live joystick values using the new calibration
+func TestCalibration(reader *HIDReader, cal JoystickCalibration) error {
+	fmt.Println("\n🧪 Testing Calibration")
+	fmt.Println("=====================")
+	fmt.Println("Move the sticks around to verify calibration")
+	fmt.Println("Values should range from -1.0 to +1.0")
+	fmt.Println("Center should be close to 0.0")
+	fmt.Println("\nPress CTRL+C to exit")
+	fmt.Println()
+
+	// Create temporary reader with new calibration
+	reader.SetCalibration(cal)
+
+	lastPrint := reader.clock.Now()
+	lRaw, rRaw := cal.StickDeadzones()
+	lDead := normalizedDeadzone(lRaw, cal.LXMin, cal.LXMax, cal.LYMin, cal.LYMax)
+	rDead := normalizedDeadzone(rRaw, cal.RXMin, cal.RXMax, cal.RYMin, cal.RYMax)
+	drawn := false
+
+	for {
+		state, err := reader.ReadStateTimeout(100 * time.Millisecond)
+		if err != nil {
+			continue
+		}
+
+		// Throttle output to avoid spam
+		if reader.clock.Now().Sub(lastPrint) < 100*time.Millisecond {
+			continue
+		}
+		lastPrint = reader.clock.Now()
+
+		j := state.Joysticks
+
+		// Show normalized values and check if they're in valid range
+		lxStatus := getStatusIcon(j.LX)
+		lyStatus := getStatusIcon(j.LY)
+		rxStatus := getStatusIcon(j.RX)
+		ryStatus := getStatusIcon(j.RY)
+
+		output := fmt.Sprintf(
+			"L: %s %+.3f, %s %+.3f | R: %s %+.3f, %s %+.3f",
+			lxStatus, j.LX, lyStatus, j.LY,
+			rxStatus, j.RX, ryStatus, j.RY,
+		)
+
+		// Redraw both grids in place
+		left := renderStickGrid(j.LX, j.LY, lDead)
+		right := renderStickGrid(j.RX, j.RY, rDead)
+		if drawn {
+			fmt.Printf("\033[%dA", len(left)+2)
+		}
+		fmt.Printf("\r\033[K%-*s   %s\n", gridWidth, "Left", "Right")
+		for i := range left {
+			fmt.Printf("\r\033[K%s   %s\n", left[i], right[i])
+		}
+		fmt.Printf("\r\033[K%s\n", output)
+		drawn = true
+	}
+}
+
+// gridRadius is the number of cells between the center and the edge of a stick grid
+const gridRadius = 6
+
+// gridWidth is the printed width of a stick grid, each cell is 2 characters wide
+const gridWidth = (2*gridRadius + 1) * 2
+
+// normalizedDeadzone converts a raw deadzone to the normalized scale, using the average half range of both axes
+func normalizedDeadzone(deadzone, xMin, xMax, yMin, yMax int) float64 {
+	halfRange := float64((xMax-xMin)+(yMax-yMin)) / 4
+	if halfRange <= 0 {
+		return 0
+	}
+	return float64(deadzone) / halfRange
+}
+
+// renderStickGrid draws a stick position (@) inside its saturation ring (#) and deadzone ring (o)
+func renderStickGrid(x, y, deadzone float64) []string {
+	px := int(math.Round(x * gridRadius))
+	py := int(math.Round(-y * gridRadius)) // Rows go down, positive Y goes up
+	half := 0.5 / gridRadius
+
+	rows := make([]string, 0, 2*gridRadius+1)
+	for row := -gridRadius; row <= gridRadius; row++ {
+		var b strings.Builder
+		for col := -gridRadius; col <= gridRadius; col++ {
+			dist := math.Hypot(float64(col), float64(row)) / gridRadius
+			switch {
+			case col == px && row == py:
+				b.WriteString("@ ")
+			case math.Abs(dist-1) < half:
+				b.WriteString("# ")
+			case deadzone > 0 && math.Abs(dist-deadzone) < half:
+				b.WriteString("o ")
+			case col == 0 && row == 0:
+				b.WriteString("+ ")
+			case dist < 1:
+				b.WriteString(". ")
+			default:
+				b.WriteString("  ")
+			}
+		}
+		rows = append(rows, b.String())
+	}
+	return rows
+}
+
+func getStatusIcon(value float64) string {
+	absVal := math.Abs(value)
+	if absVal < 0.05 {
+		return "⚪" // Near center
+	} else if absVal < 0.95 {
+		return "🟢" // Normal range
+	} else if absVal <= 1.0 {
+		return "🟡" // Edge
+	}
+	return "🔴" // Out of range (bad calibration)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// RunCalibrationWizard is a convenience function to run the full calibration process
+func RunCalibrationWizard(hidPath string) error {
+	log.Println("Opening controller for calibration...")
+
+	// Open with default calibration (we'll replace it)
+	reader, err := NewHIDReader(hidPath, DefaultCalibration)
+	if err != nil {
+		return fmt.Errorf("failed to open HID device: %w", err)
+	}
+	defer reader.Close()
+
+	// Run calibration
+	newCal, err := CalibrateJoysticks(reader)
+	if err != nil {
+		return fmt.Errorf("calibration failed: %w", err)
+	}
+
+	// Ask if user wants to test
+	fmt.Print("\nWould you like to test the calibration? (y/n): ")
+	var response string
+	fmt.Scanln(&response)
+
+	if response == "y" || response == "Y" {
+		if err := TestCalibration(reader, newCal); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// QuickCalibrate performs a fast calibration and returns the new calibration values
+// This is meant to be called programmatically without user prompts
+func QuickCalibrate(reader *HIDReader) (JoystickCalibration, error) {
+	return QuickCalibrateWithOptions(reader, DefaultQuickCalibrateOptions)
+}
+
+// QuickCalibrateOptions sets how long each step of QuickCalibrate measures
+type QuickCalibrateOptions struct {
+	Center time.Duration // Sticks left centered
+	Range  time.Duration // Sticks rotated in full circles
+	Tuning CalibrationTuning
+}
+
+// DefaultQuickCalibrateOptions matches the instructions printed by the CLI
+var DefaultQuickCalibrateOptions = QuickCalibrateOptions{
+	Center: 2 * time.Second,
+	Range:  5 * time.Second,
+	Tuning: DefaultCalibrationTuning,
+}
+
+// QuickCalibrateWithOptions measures the stick centers then ranges, without any prompt
+func QuickCalibrateWithOptions(reader *HIDReader, opts QuickCalibrateOptions) (JoystickCalibration, error) {
+	cal := JoystickCalibration{
+		Deadzone: opts.Tuning.Deadzone,
+	}
+
+	log.Println("Starting quick calibration...")
+
+	// Step 1: Measure center
+	log.Println("Measuring center position (keep sticks centered)...")
+	centerSamples := max(int(opts.Center/(40*time.Millisecond)), 1)
+	center, err := measureCenter(reader, centerSamples, 40*time.Millisecond)
+	if err != nil {
+		return cal, fmt.Errorf("center calibration error: %w", err)
+	}
+	center.apply(&cal, opts.Tuning)
+
+	log.Printf("Center recorded: L(%d,%d) R(%d,%d), deadzones L%d R%d", cal.LXCenter, cal.LYCenter, cal.RXCenter, cal.RYCenter,
+		cal.LDeadzone, cal.RDeadzone)
+
+	// Step 2: Measure range
+	log.Println("Measuring range (rotate both sticks in full circles)...")
+
+	lxMin, lyMin, rxMin, ryMin := 4095, 4095, 4095, 4095
+	lxMax, lyMax, rxMax, ryMax := 0, 0, 0, 0
+
+	startTime := reader.clock.Now()
+
+	for reader.clock.Now().Sub(startTime) < opts.Range {
+		lx, ly, rx, ry, err := readRawStickValues(reader)
+		if err != nil {
+			continue
+		}
+
+		if lx < lxMin {
+			lxMin = lx
+		}
+		if lx > lxMax {
+			lxMax = lx
+		}
+		if ly < lyMin {
+			lyMin = ly
+		}
+		if ly > lyMax {
+			lyMax = ly
+		}
+		if rx < rxMin {
+			rxMin = rx
+		}
+		if rx > rxMax {
+			rxMax = rx
+		}
+		if ry < ryMin {
+			ryMin = ry
+		}
+		if ry > ryMax {
+			ryMax = ry
+		}
+
+		reader.clock.Sleep(40 * time.Millisecond)
+	}
+
+	// Set with margin
+	margin := opts.Tuning.Margin
+	cal.LXMin = maxInt(lxMin-margin, 0)
+	cal.LXMax = minInt(lxMax+margin, 4095)
+	cal.LYMin = maxInt(lyMin-margin, 0)
+	cal.LYMax = minInt(lyMax+margin, 4095)
+	cal.RXMin = maxInt(rxMin-margin, 0)
+	cal.RXMax = minInt(rxMax+margin, 4095)
+	cal.RYMin = maxInt(ryMin-margin, 0)
+	cal.RYMax = minInt(ryMax+margin, 4095)
+
+	log.Printf("✅ Calibration complete: L(X:%d-%d, Y:%d-%d) R(X:%d-%d, Y:%d-%d)",
+		cal.LXMin, cal.LXMax, cal.LYMin, cal.LYMax,
+		cal.RXMin, cal.RXMax, cal.RYMin, cal.RYMax)
+	for _, off := range CheckCalibrationCenter(cal, opts.Tuning.CenterTolerance) {
+		log.Printf("⚠️ Off-center: %s, was a stick touched while measuring the center?", off)
+	}
+
+	return cal, nil
+}
+
+// CalibrationPath returns where the calibration of the controller with the given serial is kept in dir
+func CalibrationPath(dir, serial string) string {
+	return filepath.Join(dir, serial+".json")
+}
+
+// SaveCalibration writes a calibration file, creating its directory if needed
+func SaveCalibration(path string, cal JoystickCalibration) error {
+	data, err := json.MarshalIndent(cal, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating calibration directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing calibration: %w", err)
+	}
+	return nil
+}
+
+// LoadCalibration reads a calibration file written by SaveCalibration
+func LoadCalibration(path string) (JoystickCalibration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return JoystickCalibration{}, err
+	}
+	cal, err := ParseCalibration(data)
+	if err != nil {
+		return JoystickCalibration{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return cal, nil
+}
+
+// ParseCalibration decodes a calibration in the JSON format of SaveCalibration
+func ParseCalibration(data []byte) (JoystickCalibration, error) {
+	var cal JoystickCalibration
+	if err := json.Unmarshal(data, &cal); err != nil {
+		return JoystickCalibration{}, err
+	}
+	return cal, nil
+}
+
+// CalibrationEnv names the environment variable that can hold a calibration as JSON,
+// for deployments where mounting files is awkward
+const CalibrationEnv = "PROCON2_CALIBRATION"
+
+// CalibrationFromEnv returns the calibration in CalibrationEnv, ok is false when it is unset
+func CalibrationFromEnv() (cal JoystickCalibration, ok bool, err error) {
+	data := os.Getenv(CalibrationEnv)
+	if data == "" {
+		return JoystickCalibration{}, false, nil
+	}
+	cal, err = ParseCalibration([]byte(data))
+	if err != nil {
+		return JoystickCalibration{}, false, fmt.Errorf("parsing %s: %w", CalibrationEnv, err)
+	}
+	return cal, true, nil
+}
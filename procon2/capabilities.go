@@ -0,0 +1,75 @@
+package procon2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/gousb"
+)
+
+// Capabilities describes the features a specific controller model supports
+type Capabilities struct {
+	Paddles bool // GL/GR back buttons
+	IMU     bool // Gyroscope and accelerometer
+	Rumble  bool // Needs a writable output endpoint
+	Battery bool // Reports its battery level
+}
+
+// productCapabilities lists what each supported product ID is known to provide
+var productCapabilities = map[gousb.ID]Capabilities{
+	0x2009: {Paddles: false, IMU: true, Rumble: true, Battery: true},  // Switch Pro Controller
+	0x2019: {Paddles: false, IMU: false, Rumble: true, Battery: true}, // N64 Controller
+	0x2069: {Paddles: true, IMU: true, Rumble: true, Battery: true},   // Switch 2 Pro Controller
+}
+
+// DetectCapabilities returns the capabilities of a device from its product ID.
+// Unknown products are assumed to only provide the basic buttons and sticks.
+func DetectCapabilities(desc *gousb.DeviceDesc) Capabilities {
+	return productCapabilities[desc.Product]
+}
+
+func (c Capabilities) String() string {
+	return fmt.Sprintf("paddles=%v imu=%v rumble=%v battery=%v", c.Paddles, c.IMU, c.Rumble, c.Battery)
+}
+
+// USBInterface identifies the configuration and interface a controller is driven through.
+// A zero Config means "pick it from the product ID".
+type USBInterface struct {
+	Config    int
+	Interface int
+}
+
+// DefaultUSBInterface is where the Switch 2 controllers expose their bulk endpoints
+var DefaultUSBInterface = USBInterface{Config: 1, Interface: 1}
+
+// productInterfaces lists the products that don't use DefaultUSBInterface
+var productInterfaces = map[gousb.ID]USBInterface{
+	0x2009: {Config: 1, Interface: 0}, // Switch Pro Controller, single HID interface
+}
+
+// USBInterfaceFor returns the interface to claim for a device
+func USBInterfaceFor(desc *gousb.DeviceDesc) USBInterface {
+	if iface, ok := productInterfaces[desc.Product]; ok {
+		return iface
+	}
+	return DefaultUSBInterface
+}
+
+// ParseUSBInterface parses CONFIG:INTERFACE, e.g. "1:0". An empty string is the zero USBInterface.
+func ParseUSBInterface(spec string) (USBInterface, error) {
+	if spec == "" {
+		return USBInterface{}, nil
+	}
+	c, i, ok := strings.Cut(spec, ":")
+	config, err1 := strconv.Atoi(c)
+	iface, err2 := strconv.Atoi(i)
+	if !ok || err1 != nil || err2 != nil || config < 1 || iface < 0 {
+		return USBInterface{}, fmt.Errorf("invalid USB interface %q (expected CONFIG:INTERFACE, e.g. 1:1)", spec)
+	}
+	return USBInterface{Config: config, Interface: iface}, nil
+}
+
+func (u USBInterface) String() string {
+	return fmt.Sprintf("config %d interface %d", u.Config, u.Interface)
+}
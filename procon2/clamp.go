@@ -0,0 +1,55 @@
+package procon2
+
+import (
+	"fmt"
+	"math"
+)
+
+// StickClamp selects how normalized stick values are bounded once calibration
+// rescaled them, so sticks that go past their calibrated range stay usable
+type StickClamp int
+
+const (
+	StickClampSquare StickClamp = iota // Each axis within -1.0 - 1.0, diagonals can reach a magnitude of 1.41
+	StickClampCircle                   // The magnitude within 1.0, the direction is kept
+	StickClampNone                     // Rescaled only, past the calibrated range values go beyond 1.0
+)
+
+// ParseStickClamp converts a clamp name as used on the command line
+func ParseStickClamp(name string) (StickClamp, error) {
+	switch name {
+	case "square":
+		return StickClampSquare, nil
+	case "circle":
+		return StickClampCircle, nil
+	case "none":
+		return StickClampNone, nil
+	}
+	return StickClampSquare, fmt.Errorf("unknown stick clamp %q (expected square, circle or none)", name)
+}
+
+func (c StickClamp) String() string {
+	switch c {
+	case StickClampCircle:
+		return "circle"
+	case StickClampNone:
+		return "none"
+	default:
+		return "square"
+	}
+}
+
+// apply bounds the position of a stick
+func (c StickClamp) apply(x, y float64) (float64, float64) {
+	switch c {
+	case StickClampNone:
+		return x, y
+	case StickClampCircle:
+		if m := math.Hypot(x, y); m > 1 {
+			return x / m, y / m
+		}
+		return x, y
+	default:
+		return clampFloat(x, -1, 1), clampFloat(y, -1, 1)
+	}
+}
@@ -0,0 +1,113 @@
+package procon2
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStickClampApply(t *testing.T) {
+	tests := []struct {
+		clamp        StickClamp
+		x, y         float64
+		wantX, wantY float64
+	}{
+		// Square corner, as calibration gives it for a full diagonal
+		{StickClampSquare, 1, 1, 1, 1},
+		{StickClampCircle, 1, 1, math.Sqrt2 / 2, math.Sqrt2 / 2},
+		{StickClampNone, 1, 1, 1, 1},
+		// Past the calibrated range on one axis
+		{StickClampSquare, 1.3, 0, 1, 0},
+		{StickClampCircle, 1.3, 0, 1, 0},
+		{StickClampNone, 1.3, 0, 1.3, 0},
+		// Past the range on both axes, with a negative one
+		{StickClampSquare, -1.3, 1.2, -1, 1},
+		{StickClampCircle, -1.2, 0.9, -0.8, 0.6},
+		{StickClampNone, -1.3, 1.2, -1.3, 1.2},
+		// Within range, nothing changes
+		{StickClampSquare, 0.4, -0.3, 0.4, -0.3},
+		{StickClampCircle, 0.4, -0.3, 0.4, -0.3},
+		{StickClampNone, 0.4, -0.3, 0.4, -0.3},
+	}
+	for _, tt := range tests {
+		x, y := tt.clamp.apply(tt.x, tt.y)
+		if !near(x, tt.wantX) || !near(y, tt.wantY) {
+			t.Errorf("%s.apply(%v, %v) = (%v, %v), want (%v, %v)", tt.clamp, tt.x, tt.y, x, y, tt.wantX, tt.wantY)
+		}
+	}
+}
+
+func TestStickClampPastCalibratedRange(t *testing.T) {
+	cal := DefaultCalibration
+	// Raw readings past LXMax and LXMin rescale beyond 1.0
+	pastMax := float64(4095-cal.LXCenter-cal.Deadzone) / float64(cal.LXMax-cal.LXCenter-cal.Deadzone)
+	pastMin := -float64(cal.LXCenter-cal.Deadzone) / float64(cal.LXCenter-cal.LXMin-cal.Deadzone)
+	pastMinY := -float64(cal.LYCenter-cal.Deadzone) / float64(cal.LYCenter-cal.LYMin-cal.Deadzone)
+	pastBoth := math.Hypot(pastMax, pastMinY)
+
+	tests := []struct {
+		clamp          StickClamp
+		rawX, rawY     int
+		wantLX, wantLY float64
+	}{
+		{StickClampSquare, 4095, cal.LYCenter, 1, 0},
+		{StickClampCircle, 4095, cal.LYCenter, 1, 0},
+		{StickClampNone, 4095, cal.LYCenter, pastMax, 0},
+		{StickClampSquare, 0, cal.LYCenter, -1, 0},
+		{StickClampCircle, 0, cal.LYCenter, -1, 0},
+		{StickClampNone, 0, cal.LYCenter, pastMin, 0},
+		// Both axes past their range, the circle keeps the direction
+		{StickClampSquare, 4095, 0, 1, -1},
+		{StickClampCircle, 4095, 0, pastMax / pastBoth, pastMinY / pastBoth},
+		{StickClampNone, 4095, 0, pastMax, pastMinY},
+	}
+	for _, tt := range tests {
+		r := newHIDReader(nil, cal, HIDReaderOptions{StickClamp: tt.clamp})
+		report := make([]byte, 64)
+		report[0] = 0x09
+		putStick(report, 6, tt.rawX, tt.rawY)
+		putStick(report, 9, cal.RXCenter, cal.RYCenter)
+		j := r.parseReport(report).Joysticks
+
+		if !near(j.LX, tt.wantLX) || !near(j.LY, tt.wantLY) {
+			t.Errorf("%s raw (%d, %d): (%v, %v), want (%v, %v)", tt.clamp, tt.rawX, tt.rawY, j.LX, j.LY, tt.wantLX, tt.wantLY)
+		}
+		if j.RX != 0 || j.RY != 0 {
+			t.Errorf("%s: right stick at rest read (%v, %v)", tt.clamp, j.RX, j.RY)
+		}
+	}
+}
+
+func TestCurveOnSquareCorner(t *testing.T) {
+	soft := ResponseCurve{{0, 0}, {0.5, 0.25}, {1, 1}}
+	capped := ResponseCurve{{0, 0}, {1, 0.8}}
+
+	tests := []struct {
+		name         string
+		curve        ResponseCurve
+		x, y         float64
+		wantX, wantY float64
+	}{
+		// Past a magnitude of 1 the end of the curve scales the corner, keeping it square
+		{"soft corner", soft, 1, 1, 1, 1},
+		{"capped corner", capped, 1, 1, 0.8, 0.8},
+		{"capped past range", capped, 1.3, 0, 1.3 * 0.8, 0},
+		// Within the unit circle the curve reshapes the magnitude, keeping the direction
+		{"soft diagonal", soft, 0.3, 0.4, 0.15, 0.2},
+		{"nil curve", nil, 1, 1, 1, 1},
+	}
+	for _, tt := range tests {
+		x, y := tt.curve.applyStick(tt.x, tt.y)
+		if !near(x, tt.wantX) || !near(y, tt.wantY) {
+			t.Errorf("%s: applyStick(%v, %v) = (%v, %v), want (%v, %v)", tt.name, tt.x, tt.y, x, y, tt.wantX, tt.wantY)
+		}
+	}
+
+	// Square clamp then curve: the corner keeps the square shape, the circle one stays round
+	for _, clamp := range []StickClamp{StickClampSquare, StickClampCircle} {
+		x, y := capped.applyStick(clamp.apply(1.2, 1.2))
+		cx, cy := clamp.apply(1.2, 1.2)
+		if !near(x/cx, 0.8) || !near(y/cy, 0.8) {
+			t.Errorf("%s corner through capped curve: (%v, %v) from (%v, %v), want 0.8 of it", clamp, x, y, cx, cy)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package procon2
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the time source of the manager and readers, replaced in tests to drive
+// timeouts, tickers and scans without sleeping
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	Sleep(d time.Duration)
+}
+
+// Timer is a pending NewTimer or AfterFunc. Like time.Timer.C, Chan delivers once when a
+// NewTimer fires and is nil for AfterFunc.
+type Timer interface {
+	Chan() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker delivers ticks on Chan until stopped
+type Ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+// RealClock is the Clock of the time package
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) Chan() <-chan time.Time { return t.C }
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) Chan() <-chan time.Time { return t.C }
+
+// clockOrReal returns c, or RealClock when c is nil
+func clockOrReal(c Clock) Clock {
+	if c == nil {
+		return RealClock
+	}
+	return c
+}
+
+// withTimeout is context.WithTimeout with the deadline timed by clock
+func withTimeout(clock Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := clock.AfterFunc(d, cancel)
+	return ctx, func() {
+		t.Stop()
+		cancel()
+	}
+}
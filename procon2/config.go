@@ -0,0 +1,136 @@
+package procon2
+
+import (
+	"time"
+
+	"github.com/google/gousb"
+)
+
+// Config holds the tunables of a Manager
+type Config struct {
+	// ScanTimeout bounds a USB enumeration so a stuck USB stack can't wedge the
+	// driver. Zero waits forever.
+	ScanTimeout time.Duration
+	// HeartbeatFile is rewritten after every scan so supervisors can tell the driver
+	// is alive, see CheckHeartbeat. Empty disables it.
+	HeartbeatFile string
+	// MatchClass accepts any Nintendo HID gamepad (IsNintendoGamepad) instead of the
+	// known product IDs only (IsProController)
+	MatchClass bool
+	// ExtraProducts are Nintendo product IDs accepted on top of the known ones, e.g. new clones
+	ExtraProducts []gousb.ID
+	// ProductsFile keeps the product IDs added at runtime with Manager.AddProduct, and
+	// is read at startup. Empty keeps them in memory only.
+	ProductsFile string
+	// USBInterface overrides the interface claimed on every controller, the zero value picks it per product
+	USBInterface USBInterface
+	// InitFailRatio is the fraction of init packets allowed to fail before giving up on a controller
+	InitFailRatio float64
+	// InitDelay is the minimum wait after the init sequence before setting the player LEDs
+	InitDelay time.Duration
+	// InitPacketDelay overrides the pause between init packets of every profile, zero keeps theirs
+	InitPacketDelay time.Duration
+	// InitVerify waits for a reply after every init packet, see InitOptions.Verify
+	InitVerify bool
+	// ReadyTimeout bounds how long we wait for the first full input report after init
+	ReadyTimeout time.Duration
+	// StartupDrain discards the stale reports a controller sends right after init, see
+	// HIDReaderOptions.StartupDrain
+	StartupDrain time.Duration
+	// StallTimeout tears a controller down when no report with new content arrived for
+	// this long, catching frozen controllers that keep repeating their last report. Zero disables it.
+	StallTimeout time.Duration
+	// StallReinit re-runs the init sequence once when a controller stalls, before tearing it
+	// down if that didn't bring fresh reports back within another StallTimeout
+	StallReinit bool
+	// IdleTimeout disconnects a controller nobody touched for this long, no button pressed
+	// and the sticks at rest. It is taken again once plugged back in. Zero disables it.
+	IdleTimeout time.Duration
+	// PlayerLEDs is the LED pattern of each player, see DefaultPlayerLEDs
+	PlayerLEDs []byte
+	// CalibrationDir holds per-serial calibration files, see CalibrationPath
+	CalibrationDir string
+	// Calibration replaces DefaultCalibration for controllers without a calibration file
+	Calibration *JoystickCalibration
+	// Drift recenters worn sticks while they rest, see DriftOptions. The zero value disables it.
+	Drift DriftOptions
+	// ButtonEvents sends the presses and releases of every controller to
+	// Manager.ButtonEvents, with how long each button was held. LongPress, when
+	// not 0, also sends an EventLongPress once a button is held that long.
+	ButtonEvents bool
+	LongPress    time.Duration
+	// Stuck detects buttons a damaged controller reports as always pressed
+	Stuck StuckOptions
+	// SOCD resolves simultaneous opposing D-pad presses forwarded as buttons,
+	// Gamepad.HatSOCD does it for the hat
+	SOCD SOCDMode
+	// ReconnectGrace keeps a disconnected controller's virtual gamepad alive so it
+	// can be rebound if the same serial comes back. Zero disables it.
+	ReconnectGrace time.Duration
+	// SlotHold reserves a disconnected controller's player slot for its serial,
+	// so a transient drop doesn't reshuffle player numbers. Zero frees it immediately.
+	SlotHold time.Duration
+	// Assignments gives the player number of controllers by serial, taken when they plug in
+	Assignments map[string]int
+	// AssignPolicy decides what happens when an assigned player slot is taken
+	AssignPolicy AssignPolicy
+	// SlotsFile keeps the player number of every controller by serial, so they get it back
+	// when they plug in again, even after a restart. Assignments take precedence. Empty disables it.
+	SlotsFile string
+	// Turbo rules applied to every controller
+	Turbo []TurboRule
+	// Flick rules turning fast stick movements into button presses
+	Flick        []FlickRule
+	FlickOptions FlickOptions
+	// DryRun reads and logs controller states without creating any virtual device,
+	// to check USB, hidraw and parsing on a machine without uinput access
+	DryRun bool
+	// Outputs lists the backends every controller is forwarded to
+	Outputs []OutputKind
+	// SerialOutputs replaces Outputs for the controllers with these serials
+	SerialOutputs map[string][]OutputKind
+	// Gamepad configures the virtual gamepads
+	Gamepad GamepadOptions
+	// Bindings adds codes sent along with buttons, on the gamepad and keyboard outputs
+	Bindings Bindings
+	// Identities sets what the virtual gamepad of a serial presents as, "" for every other
+	// one. Unset, each uses the Identity of its model profile.
+	Identities map[string]VirtualIdentity
+	// Merge feeds every controller into a single virtual gamepad, Player 1: buttons held
+	// on any of them are held, and MergePolicy arbitrates the sticks. Each controller
+	// keeps its own slot and LEDs, and its other outputs.
+	Merge       bool
+	MergePolicy StickPolicy
+	// Mouse configures the virtual mice
+	Mouse MouseOptions
+	// NoRumble turns rumble off on every controller: no vibration enabling subcommand
+	// and no rumble data is sent, see Controller.SetRumbleEnabled. Manager.SetRumble
+	// switches a single one.
+	NoRumble bool
+	// HidrawPath and EvdevPath, when set, are used instead of looking the nodes of the
+	// controller up in sysfs. Only one controller is driven then, and none while several
+	// are plugged in. The hidraw node is checked against it, see CheckHidrawNode.
+	HidrawPath, EvdevPath string
+	// StickClamp bounds the normalized stick values of every controller
+	StickClamp StickClamp
+	// TraceUSB logs every USB transfer of every controller, see Controller.SetUSBTrace
+	TraceUSB bool
+	// Clock drives scans, timeouts and timers, RealClock when nil
+	Clock Clock
+}
+
+// DefaultConfig provides the settings used by the CLI when no flag overrides them
+var DefaultConfig = Config{
+	InitFailRatio: 0.5,
+	InitDelay:     100 * time.Millisecond,
+	ReadyTimeout:  2 * time.Second,
+	StartupDrain:  DefaultHIDReaderOptions.StartupDrain,
+	StallReinit:   true,
+	ScanTimeout:   5 * time.Second,
+	PlayerLEDs:    DefaultPlayerLEDs,
+	SOCD:          SOCDOff,
+	FlickOptions:  DefaultFlickOptions,
+	Outputs:       []OutputKind{OutputGamepad},
+	Gamepad:       DefaultGamepadOptions,
+	Mouse:         DefaultMouseOptions,
+}
@@ -0,0 +1,707 @@
+package procon2
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/google/gousb"
+)
+
+const (
+	VendorID = 0x057E
+	// Product IDs maintained for reference, though discovery is now strictly VID/PID based
+	ProductProcon      = 0x2069
+	USBInterfaceNumber = 1
+
+	// maxWriteAttempts bounds how many times a failed or short packet write is retried
+	maxWriteAttempts = 3
+)
+
+// Controller represents a connected Nintendo controller
+type Controller struct {
+	device    *gousb.Device
+	config    io.Closer // The *gousb.Config iface belongs to, the device can't close while it is open
+	iface     *gousb.Interface
+	epOut     *gousb.OutEndpoint
+	epIn      *gousb.InEndpoint
+	hidPath   string
+	caps      Capabilities
+	profile   *ModelProfile
+	mu        sync.Mutex // Serializes USB exchanges, guards packetID and the buffers
+	packetID  byte
+	outBuffer [64]byte
+	inBuffer  [64]byte
+	logger    Logger
+	closeOnce sync.Once
+	rumbleOff atomic.Bool
+	trace     atomic.Bool // Log every transfer, see SetUSBTrace
+	owned     []io.Closer // Device and context opened for the controller, see OpenBySerial
+	clock     Clock
+}
+
+// NewController accepts an already open USB device and initializes the interface
+func NewController(dev *gousb.Device, configNum, ifaceNum int) (*Controller, error) {
+	return NewControllerWithLogger(dev, configNum, ifaceNum, stdLogger{})
+}
+
+// NewControllerWithLogger is NewController writing its log lines to logger
+func NewControllerWithLogger(dev *gousb.Device, configNum, ifaceNum int, logger Logger) (*Controller, error) {
+	return NewControllerWithOptions(dev, configNum, ifaceNum, ControllerOptions{Logger: logger})
+}
+
+// ControllerOptions tunes NewControllerWithOptions
+type ControllerOptions struct {
+	// Logger receives the log lines of the controller, the standard logger when nil
+	Logger Logger
+	// HIDPath is the hidraw node of the controller, found through sysfs when empty.
+	// Setting it skips GetHidrawForUSB, for systems where sysfs can't be walked.
+	HIDPath string
+	// NoHIDPath skips looking for the hidraw node, for controllers that are not read
+	NoHIDPath bool
+	// Clock times the pauses and timeouts of USB exchanges, RealClock when nil
+	Clock Clock
+}
+
+// NewControllerWithOptions is NewController with chosen options
+func NewControllerWithOptions(dev *gousb.Device, configNum, ifaceNum int, opts ControllerOptions) (*Controller, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = stdLogger{}
+	}
+	clock := clockOrReal(opts.Clock)
+	cfg, intf, epOut, epIn, err := claimInterface(dev, configNum, ifaceNum, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim interface: %w", err)
+	}
+	logger.Printf("🔗 Using USB config %d interface %d", configNum, intf.Setting.Number)
+
+	// Resolve hidraw path immediately for the Reader
+	bus := dev.Desc.Bus
+	addr := dev.Desc.Address
+	hidPath := opts.HIDPath
+	if opts.NoHIDPath {
+		hidPath = ""
+	} else if hidPath != "" {
+		logger.Printf("📌 Using %s as the hidraw node", hidPath)
+	} else if hidPath, err = getHidrawForUSBWithRetry(int(bus), int(addr), logger, clock); err != nil {
+		logger.Printf("⚠️ Warning: Could not find hidraw node for Bus %d Addr %d: %v", bus, addr, err)
+	}
+
+	// Rumble needs somewhere to write to, whatever the model says
+	caps := DetectCapabilities(dev.Desc)
+	caps.Rumble = caps.Rumble && epOut != nil
+
+	profile := ProfileFor(dev.Desc)
+	logger.Printf("🧩 Using the %s profile", profile)
+
+	return &Controller{
+		device:  dev,
+		config:  cfg,
+		iface:   intf,
+		epOut:   epOut,
+		epIn:    epIn,
+		hidPath: hidPath,
+		caps:    caps,
+		profile: profile,
+		logger:  logger,
+		clock:   clock,
+	}, nil
+}
+
+// Close releases the interface and its config. The device stays open, it belongs to whoever
+// opened it and must be closed after this, unless OpenBySerial opened it. It returns the first
+// error met. Calling Close again does nothing.
+func (c *Controller) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		if c.iface != nil {
+			c.iface.Close()
+		}
+		if c.config != nil {
+			err = c.config.Close()
+		}
+		for _, closer := range c.owned {
+			if cerr := closer.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	})
+	return err
+}
+
+// Release sends the release sequence of the controller's profile, putting it back in the
+// report mode the kernel driver expects, then closes the controller. With auto-detach set
+// on the device, releasing the interface lets the kernel driver bind again.
+func (c *Controller) Release() error {
+	var err error
+	if !c.ReadOnly() {
+		c.mu.Lock()
+		for _, sc := range c.profile.ReleaseSubcommands {
+			if serr := c.sendSubcommand(sc.ID, sc.Data); serr != nil && err == nil {
+				err = serr
+			}
+			c.clock.Sleep(c.profile.InitPacketDelay)
+		}
+		for _, p := range c.profile.ReleasePackets {
+			if perr := c.writePacket(p); perr != nil && err == nil {
+				err = fmt.Errorf("release packet % x: %w", p, perr)
+			}
+			c.clock.Sleep(c.profile.InitPacketDelay)
+		}
+		c.mu.Unlock()
+	}
+	if cerr := c.Close(); cerr != nil && err == nil {
+		err = fmt.Errorf("release the interface: %w", cerr)
+	}
+	return err
+}
+
+func (c *Controller) GetHIDPath() string {
+	return c.hidPath
+}
+
+// Capabilities returns the features detected for this controller
+func (c *Controller) Capabilities() Capabilities {
+	return c.caps
+}
+
+// Profile returns how the controller model is initialized, parsed and rumbled
+func (c *Controller) Profile() *ModelProfile {
+	return c.profile
+}
+
+// SetRumbleEnabled turns rumble on or off, e.g. for players who get motion-sick from it.
+// Off, the init sequence skips the subcommands enabling vibration, subcommands carry no
+// rumble data, and haptic players tied to the controller consume frames without sending
+// them. Call it before the init sequence, or use SwitchRumble on an initialized controller.
+func (c *Controller) SetRumbleEnabled(on bool) {
+	c.rumbleOff.Store(!on)
+}
+
+// SwitchRumble is SetRumbleEnabled for an initialized controller: it also sends the
+// profile's Rumble subcommands, with zeroed data when turning rumble off
+func (c *Controller) SwitchRumble(on bool) error {
+	c.SetRumbleEnabled(on)
+	if c.ReadOnly() {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, sc := range c.profile.InitSubcommands {
+		if !sc.Rumble {
+			continue
+		}
+		data := sc.Data
+		if !on {
+			data = make([]byte, len(sc.Data))
+		}
+		if err := c.sendSubcommand(sc.ID, data); err != nil {
+			return err
+		}
+		if err := c.checkSubcommandReply(sc.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetUSBTrace logs every write to the OUT endpoint and read from the IN endpoint, with
+// byte counts and hex payloads, to diagnose init packets a clone rejects
+func (c *Controller) SetUSBTrace(on bool) {
+	c.trace.Store(on)
+}
+
+// traceTransfer logs a transfer when SetUSBTrace is on
+func (c *Controller) traceTransfer(dir string, buf []byte, n int, err error) {
+	if !c.trace.Load() {
+		return
+	}
+	n = max(0, min(n, len(buf)))
+	if err != nil {
+		c.logger.Printf("🔬 USB %s %d bytes: % x (%v)", dir, n, buf[:n], err)
+		return
+	}
+	c.logger.Printf("🔬 USB %s %d bytes: % x", dir, n, buf[:n])
+}
+
+// RumbleEnabled reports whether the controller can rumble and rumble wasn't turned off
+func (c *Controller) RumbleEnabled() bool {
+	return c.caps.Rumble && !c.rumbleOff.Load()
+}
+
+// ReadOnly reports whether the controller has no OUT endpoint, so it can't be initialized,
+// have its LEDs set or rumble. It can still be read through hidraw.
+func (c *Controller) ReadOnly() bool {
+	return c.epOut == nil
+}
+
+// SetPlayerLEDs sets the controller LEDs to the default pattern of a player (1-based)
+func (c *Controller) SetPlayerLEDs(playerNum int) error {
+	return c.SetLEDPattern(PlayerLEDPattern(DefaultPlayerLEDs, playerNum))
+}
+
+// SetLEDPattern lights the player LEDs, bit 0 being the leftmost one. When the controller
+// answers, the LEDs are read back and a mismatch is logged: some firmware reports another
+// pattern than the one lit, so it doesn't fail the call.
+func (c *Controller) SetLEDPattern(pattern byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Subcommand 0x30: Set Player Lights
+	if err := c.sendSubcommand(0x30, []byte{pattern}); err != nil {
+		return err
+	}
+	if err := c.checkSubcommandReply(0x30); err != nil {
+		return err
+	}
+
+	got, ok, err := c.getLEDPattern()
+	if err != nil || !ok {
+		return err
+	}
+	if got != pattern {
+		c.logger.Printf("⚠️ LEDs read back as 0x%02x instead of 0x%02x", got, pattern)
+	}
+	return nil
+}
+
+// GetLEDPattern reads the player LEDs back (subcommand 0x31). ok is false when the
+// controller doesn't answer.
+func (c *Controller) GetLEDPattern() (pattern byte, ok bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.getLEDPattern()
+}
+
+func (c *Controller) getLEDPattern() (pattern byte, ok bool, err error) {
+	if err := c.sendSubcommand(0x31, nil); err != nil {
+		return 0, false, err
+	}
+	data, err := c.readSubcommandReply(0x31)
+	if err != nil || len(data) == 0 {
+		return 0, false, err
+	}
+	return data[0], true, nil
+}
+
+// checkSubcommandReply looks for the reply to a subcommand and reports a NACK as an error.
+// A missing reply is not an error, not every firmware answers on this endpoint. Must be
+// called with c.mu held, like readSubcommandReply.
+func (c *Controller) checkSubcommandReply(subcmd byte) error {
+	_, err := c.readSubcommandReply(subcmd)
+	return err
+}
+
+// readSubcommandReply waits briefly for the reply to a subcommand and returns its data.
+// It returns nil data and no error when no reply arrives. Must be called with c.mu held.
+func (c *Controller) readSubcommandReply(subcmd byte) ([]byte, error) {
+	if c.epIn == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := withTimeout(c.clock, 50*time.Millisecond)
+	defer cancel()
+
+	for {
+		n, err := c.epIn.ReadContext(ctx, c.inBuffer[:])
+		c.traceTransfer("IN", c.inBuffer[:], n, err)
+		if err != nil {
+			return nil, nil
+		}
+		reply := c.inBuffer[:n]
+		if n < 15 || reply[0] != c.profile.SubcommandReply || reply[14] != subcmd {
+			continue
+		}
+		if reply[13]&0x80 == 0 {
+			return nil, fmt.Errorf("subcommand 0x%02x rejected by controller", subcmd)
+		}
+		return append([]byte(nil), reply[15:]...), nil
+	}
+}
+
+// EnableIMU turns on the motion sensors, so full reports carry IMU samples (subcommand 0x40)
+func (c *Controller) EnableIMU() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.sendSubcommand(0x40, []byte{0x01}); err != nil {
+		return err
+	}
+	return c.checkSubcommandReply(0x40)
+}
+
+// DeviceInfo is the controller's answer to the device info subcommand
+type DeviceInfo struct {
+	Firmware string // major.minor
+	Type     byte
+	MAC      string
+}
+
+// deviceTypeNames are the controller types reported in DeviceInfo
+var deviceTypeNames = map[byte]string{
+	0x01: "Joy-Con (L)",
+	0x02: "Joy-Con (R)",
+	0x03: "Pro Controller",
+}
+
+// TypeName returns a readable name for the controller type
+func (i DeviceInfo) TypeName() string {
+	if n, ok := deviceTypeNames[i.Type]; ok {
+		return n
+	}
+	return fmt.Sprintf("unknown (0x%02x)", i.Type)
+}
+
+// GetDeviceInfo asks the controller for its firmware version, type and MAC address (subcommand 0x02)
+func (c *Controller) GetDeviceInfo() (DeviceInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.sendSubcommand(0x02, nil); err != nil {
+		return DeviceInfo{}, err
+	}
+	data, err := c.readSubcommandReply(0x02)
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+	// Firmware major, minor, type, unknown, MAC (6 bytes)
+	if len(data) < 10 {
+		return DeviceInfo{}, fmt.Errorf("no device info reply")
+	}
+	mac := data[4:10]
+	return DeviceInfo{
+		Firmware: fmt.Sprintf("%d.%d", data[0], data[1]),
+		Type:     data[2],
+		MAC: fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x",
+			mac[0], mac[1], mac[2], mac[3], mac[4], mac[5]),
+	}, nil
+}
+
+// SendSubcommand sends a subcommand in the output report of the controller's profile.
+// Exchanges with the controller are serialized, so the Controller methods can be
+// called from several goroutines, e.g. LED updates during a re-init.
+func (c *Controller) SendSubcommand(subcmd byte, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sendSubcommand(subcmd, data)
+}
+
+func (c *Controller) sendSubcommand(subcmd byte, data []byte) error {
+	for i := range c.outBuffer {
+		c.outBuffer[i] = 0
+	}
+
+	c.packetID = (c.packetID + 1) & 0x0F
+
+	c.outBuffer[0] = c.profile.SubcommandReport
+	c.outBuffer[1] = c.packetID
+	if !c.rumbleOff.Load() {
+		copy(c.outBuffer[2:10], c.profile.RumblePrefix[:])
+	}
+
+	c.outBuffer[10] = subcmd
+	copy(c.outBuffer[11:], data)
+
+	if c.epOut != nil {
+		if err := c.writePacket(c.outBuffer[:]); err != nil {
+			return fmt.Errorf("subcommand 0x%02x: %w", subcmd, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("output endpoint not connected")
+}
+
+// SendRawReport writes an arbitrary output report, zero padded to 64 bytes, for probing
+// undocumented features. The report ID is its first byte.
+func (c *Controller) SendRawReport(report []byte) error {
+	if len(report) == 0 || len(report) > len(c.outBuffer) {
+		return fmt.Errorf("raw report must be 1 to %d bytes, got %d", len(c.outBuffer), len(report))
+	}
+	if c.epOut == nil {
+		return fmt.Errorf("output endpoint not connected")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.outBuffer {
+		c.outBuffer[i] = 0
+	}
+	copy(c.outBuffer[:], report)
+	return c.writePacket(c.outBuffer[:])
+}
+
+// ParseHexReport decodes a report written as hex, bytes optionally separated by spaces,
+// colons or dashes, e.g. "01 00 00 01 40 40"
+func ParseHexReport(s string) ([]byte, error) {
+	clean := strings.NewReplacer(" ", "", ":", "", "-", "", "0x", "").Replace(strings.TrimSpace(s))
+	report, err := hex.DecodeString(clean)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex report %q: %w", s, err)
+	}
+	return report, nil
+}
+
+// writePacket writes a whole packet to the output endpoint, resending it on failed or short
+// writes. Must be called with c.mu held.
+func (c *Controller) writePacket(p []byte) error {
+	var lastErr error
+	for attempt := 0; attempt < maxWriteAttempts; attempt++ {
+		n, err := c.epOut.Write(p)
+		c.traceTransfer("OUT", p, n, err)
+		if err == nil && n == len(p) {
+			return nil
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = fmt.Errorf("short write: %d/%d bytes", n, len(p))
+		}
+	}
+	return fmt.Errorf("write failed after %d attempts: %w", maxWriteAttempts, lastErr)
+}
+
+// SendInitSequence sends the initialization packets and subcommands of the controller's profile.
+// It fails when more than DefaultConfig.InitFailRatio of them could not be written.
+func (c *Controller) SendInitSequence() error {
+	return c.SendInitSequenceWithOptions(InitOptions{MaxFailRatio: DefaultConfig.InitFailRatio})
+}
+
+// InitOptions tunes SendInitSequenceWithOptions
+type InitOptions struct {
+	// MaxFailRatio is the fraction (0.0 - 1.0) of packets allowed to fail
+	MaxFailRatio float64
+	// PacketDelay is the pause after each packet, zero uses the profile's InitPacketDelay.
+	// Some clones drop packets sent faster than they handle them.
+	PacketDelay time.Duration
+	// Verify waits for a reply after every init packet, not only the profile's VerifyPackets
+	Verify bool
+}
+
+// initVerifyTimeout is how long a verified init packet waits for its reply
+const initVerifyTimeout = 100 * time.Millisecond
+
+// initDrainTimeout is how long an unverified init packet waits for a reply to discard
+const initDrainTimeout = 50 * time.Millisecond
+
+// SendInitSequenceWithOptions is SendInitSequence with chosen pacing and verification.
+// A verified packet without a reply is sent once more before counting as failed.
+func (c *Controller) SendInitSequenceWithOptions(opts InitOptions) error {
+	packets := c.profile.InitPackets
+
+	if c.epOut == nil {
+		return fmt.Errorf("output endpoint not connected")
+	}
+	delay := opts.PacketDelay
+	if delay == 0 {
+		delay = c.profile.InitPacketDelay
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logger.Printf("Sending initialization sequence...")
+	failed := 0
+	for i, p := range packets {
+		verify := c.epIn != nil && (opts.Verify || slices.Contains(c.profile.VerifyPackets, i))
+		var err error
+		for attempt := 0; attempt < 2; attempt++ {
+			if err = c.writePacket(p); err == nil && verify {
+				c.clock.Sleep(delay)
+				err = c.awaitInitReply(p)
+			}
+			if err == nil || !verify {
+				break
+			}
+		}
+		if err != nil {
+			c.logger.Printf("Failed to write packet %d: %v", i+1, err)
+			failed++
+		}
+		if verify {
+			continue
+		}
+		c.clock.Sleep(delay) // Slight delay between packets
+
+		// Try to drain input to prevent buffer overflow
+		if c.epIn != nil {
+			c.drainInput()
+		}
+	}
+
+	total := len(packets)
+	for _, sc := range c.profile.InitSubcommands {
+		if sc.Rumble && c.rumbleOff.Load() {
+			continue
+		}
+		total++
+		if err := c.sendSubcommand(sc.ID, sc.Data); err != nil {
+			c.logger.Printf("Failed to send subcommand 0x%02x: %v", sc.ID, err)
+			failed++
+		}
+		c.clock.Sleep(delay)
+	}
+
+	if float64(failed) > opts.MaxFailRatio*float64(total) {
+		return fmt.Errorf("%d/%d init packets failed", failed, total)
+	}
+	return nil
+}
+
+// drainInput reads whatever reply is pending on the IN endpoint, giving up after
+// initDrainTimeout so a controller that sends nothing can't block the init.
+// Must be called with c.mu held.
+func (c *Controller) drainInput() {
+	ctx, cancel := withTimeout(c.clock, initDrainTimeout)
+	defer cancel()
+
+	n, err := c.epIn.ReadContext(ctx, c.inBuffer[:])
+	c.traceTransfer("IN", c.inBuffer[:], n, err)
+}
+
+// awaitInitReply reads the IN endpoint until the profile's InitAck accepts a reply to
+// packet. Must be called with c.mu held.
+func (c *Controller) awaitInitReply(packet []byte) error {
+	ctx, cancel := withTimeout(c.clock, initVerifyTimeout)
+	defer cancel()
+
+	for {
+		n, err := c.epIn.ReadContext(ctx, c.inBuffer[:])
+		c.traceTransfer("IN", c.inBuffer[:], n, err)
+		if err != nil {
+			return fmt.Errorf("no reply within %v", initVerifyTimeout)
+		}
+		if c.profile.InitAck == nil || c.profile.InitAck(packet, c.inBuffer[:n]) {
+			return nil
+		}
+	}
+}
+
+// WaitForFullReport reads the hidraw node until a full input report shows up,
+// confirming the init sequence switched the controller to full-report mode
+func (c *Controller) WaitForFullReport(timeout time.Duration) error {
+	if c.hidPath == "" {
+		return fmt.Errorf("no HID path found")
+	}
+
+	// Raw non-blocking fd, so a silent controller can't block us past the timeout
+	fd, err := syscall.Open(c.hidPath, syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("open hidraw: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	// Its own buffer, subcommand exchanges can run meanwhile
+	var buf [64]byte
+	deadline := c.clock.Now().Add(timeout)
+	for c.clock.Now().Before(deadline) {
+		n, err := syscall.Read(fd, buf[:])
+		if err != nil || n <= 0 {
+			c.clock.Sleep(5 * time.Millisecond)
+			continue
+		}
+		report := stripReportPrefix(buf[:n])
+		if _, full := sticksAt(report[0]); full {
+			return nil
+		}
+	}
+	return fmt.Errorf("no full input report within %v", timeout)
+}
+
+// claimInterface opens config configNum of dev and claims an interface on it, ifaceNum or
+// another one with an OUT endpoint. The config stays open until the interface is released.
+func claimInterface(dev *gousb.Device, configNum int, ifaceNum int, logger Logger) (*gousb.Config, *gousb.Interface, *gousb.OutEndpoint, *gousb.InEndpoint, error) {
+	cfg, err := dev.Config(configNum)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to open config %d: %w", configNum, err)
+	}
+
+	intf, epOut, epIn, err := claimEndpoints(cfg, ifaceNum)
+	if err == nil && epOut != nil {
+		return cfg, intf, epOut, epIn, nil
+	}
+
+	// Clones may expose the controller on another interface, look for one with an OUT endpoint
+	for _, desc := range cfg.Desc.Interfaces {
+		if desc.Number == ifaceNum {
+			continue
+		}
+		alt, altOut, altIn, altErr := claimEndpoints(cfg, desc.Number)
+		if altErr != nil {
+			continue
+		}
+		if altOut == nil {
+			alt.Close()
+			continue
+		}
+		if intf != nil {
+			intf.Close()
+		}
+		logger.Printf("🔁 Interface %d has no usable endpoints, using interface %d instead", ifaceNum, desc.Number)
+		return cfg, alt, altOut, altIn, nil
+	}
+
+	if err != nil {
+		cfg.Close()
+		return nil, nil, nil, nil, fmt.Errorf("failed to claim interface %d: %w", ifaceNum, err)
+	}
+	// Input still arrives over hidraw, the controller can be used read-only
+	logger.Printf("📖 No OUT endpoint on interface %d or any other interface (interface %d has %s), running read-only",
+		ifaceNum, ifaceNum, describeEndpoints(intf.Setting))
+	return cfg, intf, nil, epIn, nil
+}
+
+// claimEndpoints claims an interface and opens its OUT and interrupt/bulk IN endpoints, if any.
+// A bulk OUT endpoint is preferred, interrupt OUT is used when there is none.
+func claimEndpoints(cfg *gousb.Config, ifaceNum int) (*gousb.Interface, *gousb.OutEndpoint, *gousb.InEndpoint, error) {
+	intf, err := cfg.Interface(ifaceNum, 0)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var epOut *gousb.OutEndpoint
+	var epIn *gousb.InEndpoint
+	outBulk := false
+
+	for _, e := range intf.Setting.Endpoints {
+		if e.Direction == gousb.EndpointDirectionOut && !outBulk &&
+			(e.TransferType == gousb.TransferTypeBulk || (e.TransferType == gousb.TransferTypeInterrupt && epOut == nil)) {
+			epOut, err = intf.OutEndpoint(e.Number)
+			if err != nil {
+				intf.Close()
+				return nil, nil, nil, err
+			}
+			outBulk = e.TransferType == gousb.TransferTypeBulk
+		}
+		if e.Direction == gousb.EndpointDirectionIn && (e.TransferType == gousb.TransferTypeInterrupt || e.TransferType == gousb.TransferTypeBulk) {
+			epIn, err = intf.InEndpoint(e.Number)
+			if err != nil {
+				intf.Close()
+				return nil, nil, nil, err
+			}
+		}
+	}
+
+	return intf, epOut, epIn, nil
+}
+
+// describeEndpoints lists an interface's endpoints for error messages
+func describeEndpoints(setting gousb.InterfaceSetting) string {
+	if len(setting.Endpoints) == 0 {
+		return "no endpoints"
+	}
+	var eps []string
+	for _, e := range setting.Endpoints {
+		eps = append(eps, fmt.Sprintf("%s %s %s", e.Address, e.Direction, e.TransferType))
+	}
+	sort.Strings(eps)
+	return "endpoints " + strings.Join(eps, ", ")
+}
@@ -0,0 +1,45 @@
+package procon2
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+// usbHandle mimics gousb, which refuses to close a context, device or config while
+// something opened from it is still open
+type usbHandle struct {
+	name     string
+	closed   bool
+	children []*usbHandle
+}
+
+func (h *usbHandle) Close() error {
+	for _, c := range h.children {
+		if !c.closed {
+			return fmt.Errorf("can't close %s, %s is still open", h.name, c.name)
+		}
+	}
+	h.closed = true
+	return nil
+}
+
+func TestControllerCloseReleasesOwned(t *testing.T) {
+	config := &usbHandle{name: "config"}
+	dev := &usbHandle{name: "device", children: []*usbHandle{config}}
+	ctx := &usbHandle{name: "context", children: []*usbHandle{dev}}
+
+	// Laid out like OpenBySerial leaves it
+	c := &Controller{config: config, owned: []io.Closer{dev, ctx}}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for _, h := range []*usbHandle{config, dev, ctx} {
+		if !h.closed {
+			t.Errorf("%s still open after Close", h.name)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package procon2
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CurvePoint maps a stick magnitude, from 0.0 (center) to 1.0 (full tilt), to the one forwarded
+type CurvePoint struct {
+	In, Out float64
+}
+
+// ResponseCurve reshapes how far a stick reads as tilted, interpolating linearly between
+// its points. It is applied to the stick magnitude after the deadzone, so the direction is
+// kept. A nil curve forwards the magnitude unchanged.
+type ResponseCurve []CurvePoint
+
+// ParseResponseCurve parses IN:OUT points separated by commas or spaces, e.g.
+// "0:0,0.5:0.2,1:1" for a finer aim near the center. The curve is validated.
+func ParseResponseCurve(spec string) (ResponseCurve, error) {
+	var curve ResponseCurve
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		in, out, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid curve point %q (expected IN:OUT)", item)
+		}
+		p, err := parseCurvePoint(in, out)
+		if err != nil {
+			return nil, fmt.Errorf("curve point %q: %w", item, err)
+		}
+		curve = append(curve, p)
+	}
+	if err := curve.Validate(); err != nil {
+		return nil, err
+	}
+	return curve, nil
+}
+
+// LoadResponseCurve reads a curve exported by another tool: one point per line, as IN,OUT,
+// IN:OUT or IN OUT. Empty lines and lines starting with # are skipped.
+func LoadResponseCurve(path string) (ResponseCurve, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var curve ResponseCurve
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ':' || r == ' ' || r == '\t' })
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected IN,OUT", path, i+1)
+		}
+		p, err := parseCurvePoint(fields[0], fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		curve = append(curve, p)
+	}
+	if err := curve.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return curve, nil
+}
+
+func parseCurvePoint(in, out string) (CurvePoint, error) {
+	x, err := strconv.ParseFloat(strings.TrimSpace(in), 64)
+	if err != nil {
+		return CurvePoint{}, fmt.Errorf("invalid input %q", in)
+	}
+	y, err := strconv.ParseFloat(strings.TrimSpace(out), 64)
+	if err != nil {
+		return CurvePoint{}, fmt.Errorf("invalid output %q", out)
+	}
+	return CurvePoint{In: x, Out: y}, nil
+}
+
+// Validate checks the curve covers 0.0 to 1.0 with increasing inputs and outputs that
+// never go down, so pushing a stick further never makes it read as less tilted
+func (c ResponseCurve) Validate() error {
+	if len(c) < 2 {
+		return fmt.Errorf("a response curve needs at least 2 points, got %d", len(c))
+	}
+	if c[0].In != 0 || c[len(c)-1].In != 1 {
+		return fmt.Errorf("a response curve must start at input 0 and end at input 1")
+	}
+	for i, p := range c {
+		if p.Out < 0 || p.Out > 1 {
+			return fmt.Errorf("curve output %v is outside 0 to 1", p.Out)
+		}
+		if i == 0 {
+			continue
+		}
+		if p.In <= c[i-1].In {
+			return fmt.Errorf("curve inputs must increase, %v follows %v", p.In, c[i-1].In)
+		}
+		if p.Out < c[i-1].Out {
+			return fmt.Errorf("curve outputs must not decrease, %v follows %v", p.Out, c[i-1].Out)
+		}
+	}
+	return nil
+}
+
+// Apply returns the magnitude forwarded for m, clamped to 0.0 - 1.0
+func (c ResponseCurve) Apply(m float64) float64 {
+	m = math.Max(0, math.Min(1, m))
+	if len(c) == 0 {
+		return m
+	}
+	for i := 1; i < len(c); i++ {
+		if m <= c[i].In {
+			a, b := c[i-1], c[i]
+			return a.Out + (b.Out-a.Out)*(m-a.In)/(b.In-a.In)
+		}
+	}
+	return c[len(c)-1].Out
+}
+
+// applyStick reshapes the magnitude of a stick position, keeping its direction. Past a
+// magnitude of 1.0, e.g. square corners, the end of the curve is scaled along, so the
+// curve leaves the shape StickClamp gave the stick range alone.
+func (c ResponseCurve) applyStick(x, y float64) (float64, float64) {
+	m := math.Hypot(x, y)
+	if len(c) == 0 || m == 0 {
+		return x, y
+	}
+	scale := c.Apply(m) / m
+	if m > 1 {
+		scale = c.Apply(1)
+	}
+	return x * scale, y * scale
+}
@@ -1,4 +1,4 @@
-package main
+package procon2
 
 import (
 	"encoding/hex"
@@ -13,8 +13,21 @@ type DisplayOptions struct {
 	ShowRawValues bool
 	ShowDirection bool
 	UpdateRate    time.Duration
+	// Deadzone is applied to the normalized stick values shown, like GamepadOptions.Deadzone,
+	// so the monitor shows what games get. Zero shows them as the reader parsed them.
+	Deadzone float64
 }
 
+// DefaultDisplayOptions shows the sticks as the default virtual gamepad forwards them
+var DefaultDisplayOptions = DisplayOptions{
+	ShowDirection: true,
+	UpdateRate:    16 * time.Millisecond,
+	Deadzone:      DefaultGamepadOptions.Deadzone,
+}
+
+// monitorChangeThreshold is the normalized stick movement that redraws the button monitor
+const monitorChangeThreshold = 0.02
+
 // InputMonitor monitors and displays controller input
 type InputMonitor struct {
 	reader    *HIDReader
@@ -34,19 +47,19 @@ func NewInputMonitor(reader *HIDReader, opts DisplayOptions) *InputMonitor {
 func (m *InputMonitor) RunButtonMonitor() error {
 	fmt.Println("📡 Controller Input Monitor")
 	fmt.Println("✅ Ready! Press buttons and move joysticks.")
-	fmt.Println("Press CTRL+C to quit.\n")
-
-	const changeThreshold = 50.0 // threshold in raw units
+	fmt.Println("Press CTRL+C to quit.")
+	fmt.Println()
 
 	for {
 		state, err := m.reader.ReadState()
 		if err != nil {
 			continue
 		}
+		state.Joysticks = state.Joysticks.ApplyDeadzone(m.opts.Deadzone)
 
 		// Check if anything changed
 		buttonsChanged := !state.ButtonsEqual(m.lastState)
-		joysticksChanged := state.JoysticksChanged(m.lastState, changeThreshold)
+		joysticksChanged := state.JoysticksChanged(m.lastState, monitorChangeThreshold)
 
 		if buttonsChanged || joysticksChanged {
 			output := m.formatState(state)
@@ -54,7 +67,7 @@ func (m *InputMonitor) RunButtonMonitor() error {
 			m.lastState = state
 		}
 
-		time.Sleep(m.opts.UpdateRate)
+		m.reader.clock.Sleep(m.opts.UpdateRate)
 	}
 }
 
@@ -62,7 +75,8 @@ func (m *InputMonitor) RunButtonMonitor() error {
 func (m *InputMonitor) RunStickViewer() error {
 	fmt.Println("📡 Joystick Viewer")
 	fmt.Println("✅ Move both sticks to see detailed values")
-	fmt.Println("Press CTRL+C to quit.\n")
+	fmt.Println("Press CTRL+C to quit.")
+	fmt.Println()
 
 	for {
 		state, err := m.reader.ReadState()
@@ -72,12 +86,17 @@ func (m *InputMonitor) RunStickViewer() error {
 
 		output := m.formatJoysticks(state)
 		fmt.Printf("\r\033[K%s", output)
-		time.Sleep(m.opts.UpdateRate)
+		m.reader.clock.Sleep(m.opts.UpdateRate)
 	}
 }
 
 // formatState formats the complete controller state
 func (m *InputMonitor) formatState(state ControllerState) string {
+	return formatStateLine(state)
+}
+
+// formatStateLine formats pressed buttons and raw stick values on a single line
+func formatStateLine(state ControllerState) string {
 	var parts []string
 
 	// Format buttons
@@ -87,10 +106,9 @@ func (m *InputMonitor) formatState(state ControllerState) string {
 	}
 
 	// Format joysticks (raw ints)
-	j := state.Joysticks
 	joystickStr := fmt.Sprintf(
 		"L-XY: (%4d, %4d) | R-XY: (%4d, %4d)",
-		j.LX, j.LY, j.RX, j.RY,
+		state.RawLX, state.RawLY, state.RawRX, state.RawRY,
 	)
 	parts = append(parts, joystickStr)
 
@@ -103,28 +121,20 @@ func (m *InputMonitor) formatState(state ControllerState) string {
 
 // formatJoysticks formats detailed joystick information
 func (m *InputMonitor) formatJoysticks(state ControllerState) string {
-	j := state.Joysticks
-
-	// Convert raw ints to float64 for direction helper
-	lx := float64(j.LX)
-	ly := float64(j.LY)
-	rx := float64(j.RX)
-	ry := float64(j.RY)
-
-	// Deadzone in raw units (example: 300 ~ small deadzone)
-	deadzone := 300.0
+	// The same deadzone as the virtual gamepad, so CENTER means games see no movement
+	j := state.Joysticks.ApplyDeadzone(m.opts.Deadzone)
 
-	lDir := GetStickDirection(lx, ly, deadzone)
-	rDir := GetStickDirection(rx, ry, deadzone)
+	lDir := GetStickDirection(j.LX, j.LY, m.opts.Deadzone)
+	rDir := GetStickDirection(j.RX, j.RY, m.opts.Deadzone)
 
 	output := fmt.Sprintf(
-		"L(%4d, %4d) %-8s | R(%4d, %4d) %-8s",
+		"L(%+.3f, %+.3f) %-8s | R(%+.3f, %+.3f) %-8s",
 		j.LX, j.LY, lDir,
 		j.RX, j.RY, rDir,
 	)
 
 	if m.opts.ShowRawValues {
-		rawStr := fmt.Sprintf(" | RAW L(%4d,%4d) R(%4d,%4d)", j.LX, j.LY, j.RX, j.RY)
+		rawStr := fmt.Sprintf(" | RAW L(%4d,%4d) R(%4d,%4d)", state.RawLX, state.RawLY, state.RawRX, state.RawRY)
 		output += rawStr
 	}
 
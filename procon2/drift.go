@@ -0,0 +1,53 @@
+package procon2
+
+import (
+	"math"
+	"time"
+)
+
+// DriftOptions configures anti-drift recentering: while a stick rests near its center,
+// the effective center slowly follows the raw reading so worn sticks still output zero.
+// A zero Rate disables it.
+type DriftOptions struct {
+	Threshold     float64       // Normalized deflection below which a stick counts as released
+	Settle        time.Duration // How long a stick must stay released before its center moves
+	Rate          float64       // Fraction of the remaining offset corrected per report
+	MaxCorrection int           // Bound on the center shift in raw units, so a stuck stick isn't hidden
+}
+
+// DefaultDriftOptions corrects slowly and never more than a few percent of the stick range
+var DefaultDriftOptions = DriftOptions{
+	Threshold:     0.15,
+	Settle:        500 * time.Millisecond,
+	Rate:          0.01,
+	MaxCorrection: 150,
+}
+
+// driftCompensator tracks the center correction of one stick
+type driftCompensator struct {
+	x, y     float64   // Added to the calibrated center
+	released time.Time // Since when the stick rests, zero while it is deflected
+}
+
+// update moves the correction toward the raw reading once the stick has rested long enough.
+// x and y are the normalized output computed with the current correction.
+func (d *driftCompensator) update(opts DriftOptions, rawX, rawY, centerX, centerY int, x, y float64, now time.Time) {
+	if math.Hypot(x, y) >= opts.Threshold {
+		d.released = time.Time{}
+		return
+	}
+	if d.released.IsZero() {
+		d.released = now
+	}
+	if now.Sub(d.released) < opts.Settle {
+		return
+	}
+
+	limit := float64(opts.MaxCorrection)
+	d.x = clampFloat(d.x+(float64(rawX-centerX)-d.x)*opts.Rate, -limit, limit)
+	d.y = clampFloat(d.y+(float64(rawY-centerY)-d.y)*opts.Rate, -limit, limit)
+}
+
+func clampFloat(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
@@ -0,0 +1,94 @@
+package procon2
+
+import "time"
+
+// ButtonEventType is what happened to a button
+type ButtonEventType int
+
+const (
+	EventButtonDown ButtonEventType = iota
+	EventButtonUp                   // Held is how long the button was down
+	EventLongPress                  // Fired once while still held, after the long-press threshold
+)
+
+func (t ButtonEventType) String() string {
+	switch t {
+	case EventButtonDown:
+		return "down"
+	case EventButtonUp:
+		return "up"
+	case EventLongPress:
+		return "long-press"
+	}
+	return "unknown"
+}
+
+// ButtonEvent is a single button change
+type ButtonEvent struct {
+	Type   ButtonEventType
+	Button Button
+	Held   time.Duration // Zero for EventButtonDown
+	Time   time.Time
+}
+
+// PlayerButtonEvent is a button event of one running controller, see Manager.ButtonEvents
+type PlayerButtonEvent struct {
+	ButtonEvent
+	Slot     int // 0 to 3 (Player 1-4)
+	UniqueID string
+}
+
+// buttonEventBuffer is how many button events wait for a slow consumer before new ones are dropped
+const buttonEventBuffer = 64
+
+// ButtonEvents returns the button events of every controller, once Config.ButtonEvents is
+// set. Like Events, events are dropped when the buffer is full and the channel is never closed.
+func (m *Manager) ButtonEvents() <-chan PlayerButtonEvent {
+	return m.buttons
+}
+
+// emitButton sends a button event without blocking
+func (m *Manager) emitButton(e PlayerButtonEvent) {
+	select {
+	case m.buttons <- e:
+	default:
+	}
+}
+
+// ButtonTracker turns successive controller states into button events, timing each press
+// so short and long presses of the same button can be told apart
+type ButtonTracker struct {
+	longPress time.Duration
+	pressed   map[Button]time.Time
+	longFired map[Button]bool
+}
+
+// NewButtonTracker creates a tracker, longPress 0 disables EventLongPress events
+func NewButtonTracker(longPress time.Duration) *ButtonTracker {
+	return &ButtonTracker{
+		longPress: longPress,
+		pressed:   make(map[Button]time.Time),
+		longFired: make(map[Button]bool),
+	}
+}
+
+// Feed returns the events between the previous state and this one, in AllButtons order
+func (t *ButtonTracker) Feed(state ControllerState, now time.Time) []ButtonEvent {
+	var events []ButtonEvent
+	for _, b := range AllButtons {
+		since, wasDown := t.pressed[b]
+		switch down := state.Pressed(b); {
+		case down && !wasDown:
+			t.pressed[b] = now
+			events = append(events, ButtonEvent{Type: EventButtonDown, Button: b, Time: now})
+		case down && t.longPress > 0 && !t.longFired[b] && now.Sub(since) >= t.longPress:
+			t.longFired[b] = true
+			events = append(events, ButtonEvent{Type: EventLongPress, Button: b, Held: now.Sub(since), Time: now})
+		case !down && wasDown:
+			delete(t.pressed, b)
+			delete(t.longFired, b)
+			events = append(events, ButtonEvent{Type: EventButtonUp, Button: b, Held: now.Sub(since), Time: now})
+		}
+	}
+	return events
+}
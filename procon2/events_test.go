@@ -0,0 +1,38 @@
+package procon2
+
+import (
+	"testing"
+	"time"
+)
+
+func TestButtonTrackerHoldDurations(t *testing.T) {
+	tr := NewButtonTracker(500 * time.Millisecond)
+	start := time.Unix(1700000000, 0)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	steps := []struct {
+		name  string
+		state ControllerState
+		ms    int
+		want  []ButtonEvent
+	}{
+		{"A pressed", ControllerState{A: true}, 0, []ButtonEvent{{Type: EventButtonDown, Button: ButtonA, Time: at(0)}}},
+		{"A still held", ControllerState{A: true}, 200, nil},
+		{"A short press released", ControllerState{}, 300, []ButtonEvent{{Type: EventButtonUp, Button: ButtonA, Held: 300 * time.Millisecond, Time: at(300)}}},
+		{"B pressed", ControllerState{B: true}, 1000, []ButtonEvent{{Type: EventButtonDown, Button: ButtonB, Time: at(1000)}}},
+		{"B long press", ControllerState{B: true}, 1600, []ButtonEvent{{Type: EventLongPress, Button: ButtonB, Held: 600 * time.Millisecond, Time: at(1600)}}},
+		{"B long press fires once", ControllerState{B: true}, 2000, nil},
+		{"B released", ControllerState{}, 2200, []ButtonEvent{{Type: EventButtonUp, Button: ButtonB, Held: 1200 * time.Millisecond, Time: at(2200)}}},
+	}
+	for _, step := range steps {
+		got := tr.Feed(step.state, at(step.ms))
+		if len(got) != len(step.want) {
+			t.Fatalf("%s: got %+v, want %+v", step.name, got, step.want)
+		}
+		for i := range got {
+			if got[i] != step.want[i] {
+				t.Fatalf("%s: got %+v, want %+v", step.name, got[i], step.want[i])
+			}
+		}
+	}
+}
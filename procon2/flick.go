@@ -0,0 +1,128 @@
+package procon2
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// flickPulse is how long the mapped button stays pressed after a flick
+const flickPulse = 50 * time.Millisecond
+
+// FlickRule presses Button when a stick is flicked fast enough in Direction
+type FlickRule struct {
+	LeftStick bool   // false for the right stick
+	Direction string // UP, DOWN, LEFT or RIGHT
+	Button    Button
+}
+
+// FlickOptions tunes the flick detector shared by all rules
+type FlickOptions struct {
+	Threshold      float64       // Stick velocity, in normalized units per second
+	Cooldown       time.Duration // Minimum time between two flicks of the same rule
+	SuppressAnalog bool          // Center sticks that have flick rules instead of forwarding them
+}
+
+// DefaultFlickOptions fires on a full deflection in about 125ms
+var DefaultFlickOptions = FlickOptions{
+	Threshold: 8.0,
+	Cooldown:  250 * time.Millisecond,
+}
+
+// ParseFlickRules parses a comma separated list of STICK:DIRECTION=BUTTON rules,
+// e.g. "L:RIGHT=R,R:UP=X" where STICK is L or R
+func ParseFlickRules(spec string) ([]FlickRule, error) {
+	var rules []FlickRule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		input, button, ok := strings.Cut(item, "=")
+		stick, dir, ok2 := strings.Cut(input, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid flick rule %q (expected STICK:DIRECTION=BUTTON)", item)
+		}
+
+		rule := FlickRule{Direction: strings.ToUpper(dir)}
+		switch strings.ToUpper(stick) {
+		case "L":
+			rule.LeftStick = true
+		case "R":
+		default:
+			return nil, fmt.Errorf("invalid stick %q in flick rule %q", stick, item)
+		}
+		switch rule.Direction {
+		case "UP", "DOWN", "LEFT", "RIGHT":
+		default:
+			return nil, fmt.Errorf("invalid direction %q in flick rule %q", dir, item)
+		}
+
+		var err error
+		if rule.Button, err = ParseButton(button); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Flick turns fast stick movements into button presses
+type Flick struct {
+	rules    []FlickRule
+	opts     FlickOptions
+	fired    []time.Time
+	prev     JoystickValues
+	prevTime time.Time
+}
+
+// NewFlick creates a flick detector for the given rules
+func NewFlick(rules []FlickRule, opts FlickOptions) *Flick {
+	return &Flick{rules: rules, opts: opts, fired: make([]time.Time, len(rules))}
+}
+
+// Apply returns state with flick buttons pressed, measuring velocity against the previous call
+func (f *Flick) Apply(state ControllerState, now time.Time) ControllerState {
+	sticks := state.Joysticks
+	dt := now.Sub(f.prevTime).Seconds()
+
+	for i, r := range f.rules {
+		x, y, px, py := sticks.RX, sticks.RY, f.prev.RX, f.prev.RY
+		if r.LeftStick {
+			x, y, px, py = sticks.LX, sticks.LY, f.prev.LX, f.prev.LY
+		}
+
+		if !f.prevTime.IsZero() && dt > 0 && now.Sub(f.fired[i]) >= f.opts.Cooldown {
+			// Positive Y is up on normalized values
+			var v float64
+			switch r.Direction {
+			case "UP":
+				v = (y - py) / dt
+			case "DOWN":
+				v = (py - y) / dt
+			case "RIGHT":
+				v = (x - px) / dt
+			case "LEFT":
+				v = (px - x) / dt
+			}
+			if v > f.opts.Threshold {
+				f.fired[i] = now
+			}
+		}
+
+		if now.Sub(f.fired[i]) < flickPulse {
+			state.SetPressed(r.Button, true)
+		}
+		if f.opts.SuppressAnalog {
+			if r.LeftStick {
+				state.Joysticks.LX, state.Joysticks.LY = 0, 0
+			} else {
+				state.Joysticks.RX, state.Joysticks.RY = 0, 0
+			}
+		}
+	}
+
+	f.prev, f.prevTime = sticks, now
+	return state
+}
@@ -0,0 +1,230 @@
+package procon2
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// HapticPattern represents a haptic vibration pattern
+type HapticPattern [][]byte
+
+// DefaultHapticPattern is a simple test pattern
+var DefaultHapticPattern = HapticPattern{
+	{0x93, 0x35, 0x36, 0x1c, 0x0d},
+	{0xa8, 0x29, 0xc5, 0xdc, 0x0c},
+	{0x75, 0x21, 0xb5, 0x5d, 0x13},
+}
+
+// DefaultSwitch1HapticPattern is a short test pattern in HapticFormatSwitch1, 320Hz/160Hz
+// at strong, medium then strong amplitude
+var DefaultSwitch1HapticPattern = HapticPattern{
+	{0x00, 0xb5, 0x40, 0x6d},
+	{0x00, 0x89, 0x40, 0x62},
+	{0x00, 0xb5, 0x40, 0x6d},
+}
+
+// switch1NeutralFrame is HD rumble silence
+var switch1NeutralFrame = []byte{0x00, 0x01, 0x40, 0x40}
+
+// HapticFormat is the layout of haptic frames and of the report carrying them
+type HapticFormat int
+
+const (
+	HapticFormatSwitch2 HapticFormat = iota // 5-byte frames, in the report chosen by HapticOptions.ReportID
+	HapticFormatSwitch1                     // 4-byte HD rumble frames, in report 0x10 for both motors
+)
+
+// Haptic output report IDs
+const (
+	HapticReportCombined = 0x02 // Haptics mirrored for both motors inside the combined output report
+	// HapticReportRumble is the rumble-only output report, frames for both motors back to
+	// back. HapticFormatSwitch1 always uses it. Experimental with HapticFormatSwitch2:
+	// 5-byte frames in 0x10 haven't been checked on a real controller, so they are only
+	// sent with HapticOptions.Experimental set.
+	HapticReportRumble = 0x10
+)
+
+// HapticPlayer handles haptic feedback
+type HapticPlayer struct {
+	file   *os.File
+	report [64]byte
+	ctrl   *Controller // Checked before each frame, nil when not tied to a controller
+}
+
+// NewHapticPlayer opens a HID device for haptic output
+func NewHapticPlayer(hidPath string) (*HapticPlayer, error) {
+	f, err := os.OpenFile(hidPath, os.O_RDWR|os.O_SYNC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open hidraw: %w (try running as root or add udev rule)", err)
+	}
+
+	return &HapticPlayer{file: f, report: [64]byte{}}, nil
+}
+
+// NewHapticPlayerFor opens the HID device of ctrl for haptic output. Frames are dropped
+// while ctrl has rumble turned off, see Controller.SetRumbleEnabled.
+func NewHapticPlayerFor(ctrl *Controller) (*HapticPlayer, error) {
+	h, err := NewHapticPlayer(ctrl.GetHIDPath())
+	if err != nil {
+		return nil, err
+	}
+	h.ctrl = ctrl
+	return h, nil
+}
+
+// Close closes the haptic device
+func (h *HapticPlayer) Close() error {
+	if h.file != nil {
+		return h.file.Close()
+	}
+	return nil
+}
+
+// HapticOptions changes how a pattern is repeated and ended
+type HapticOptions struct {
+	Loop      bool   // Replay the pattern until the timeout, which then isn't an error
+	NoStop    bool   // Don't send a stop frame, e.g. when the caller manages stopping itself
+	StopFrame []byte // Frame data sent as the stop report instead of silence, when not empty
+	ReportID  byte   // Output report carrying the frames, HapticReportCombined when zero
+	Format    HapticFormat
+	// Experimental allows report layouts not checked on a real controller yet, see HapticReportRumble
+	Experimental bool
+}
+
+// Play plays a haptic pattern with the specified frame interval and timeout
+func (h *HapticPlayer) Play(pattern HapticPattern, frameInterval time.Duration, timeout time.Duration) error {
+	return h.PlayWithOptions(pattern, frameInterval, timeout, HapticOptions{})
+}
+
+// PlayWithOptions plays a haptic pattern, repeating and ending it as opts says
+func (h *HapticPlayer) PlayWithOptions(pattern HapticPattern, frameInterval time.Duration, timeout time.Duration, opts HapticOptions) error {
+	if len(pattern) == 0 && opts.Loop {
+		return errors.New("cannot loop an empty haptic pattern")
+	}
+	if opts.Format == HapticFormatSwitch2 && opts.ReportID == HapticReportRumble && !opts.Experimental {
+		return errors.New("haptics in report 0x10 are experimental, set HapticOptions.Experimental to send them")
+	}
+
+	ticker := time.NewTicker(frameInterval)
+	defer ticker.Stop()
+
+	done := make(chan error, 1)
+	quit := make(chan struct{})
+
+	go func() {
+		counter := byte(0)
+
+	frames:
+		for i := 0; i < len(pattern) || opts.Loop; i++ {
+			select {
+			case <-ticker.C:
+			case <-quit:
+				if !opts.Loop {
+					return
+				}
+				// The loop ran for the whole timeout, end it normally
+				break frames
+			}
+
+			frame := pattern[i%len(pattern)]
+			if err := h.writeFrame(opts, counter, frame); err != nil {
+				done <- fmt.Errorf("frame %d: %w", i%len(pattern), err)
+				return
+			}
+
+			if !opts.Loop {
+				log.Printf("Sent haptic frame %d/%d (counter 0x%02x)", i+1, len(pattern), counter)
+			}
+			counter = (counter + 1) & 0x0F
+		}
+
+		if !opts.NoStop {
+			// Let the last frame play for a full interval, a finished loop already did
+			if !opts.Loop {
+				select {
+				case <-ticker.C:
+				case <-quit:
+					return
+				}
+			}
+			if err := h.writeFrame(opts, 0, opts.StopFrame); err != nil {
+				done <- fmt.Errorf("error sending stop report: %w", err)
+				return
+			}
+			log.Println("Sent haptic stop report")
+		}
+
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		close(quit)
+		if opts.Loop {
+			return <-done
+		}
+		return errors.New("haptics timed out")
+	}
+}
+
+// writeFrame sends one haptic output report, a nil frame being silence
+func (h *HapticPlayer) writeFrame(opts HapticOptions, counter byte, frame []byte) error {
+	if h.ctrl != nil && !h.ctrl.RumbleEnabled() {
+		return nil
+	}
+	for j := range h.report {
+		h.report[j] = 0
+	}
+
+	if opts.Format == HapticFormatSwitch1 {
+		if frame == nil {
+			frame = switch1NeutralFrame
+		}
+		h.report[0] = HapticReportRumble
+		h.report[1] = counter & 0x0F
+		copy(h.report[2:6], frame)
+		copy(h.report[6:10], frame)
+		return h.write()
+	}
+
+	switch opts.ReportID {
+	case 0, HapticReportCombined:
+		h.report[0] = HapticReportCombined
+		h.report[1] = 0x50 | (counter & 0x0F)
+		h.report[17] = h.report[1]
+
+		// Copy frame data into the pre-allocated slots
+		copy(h.report[2:7], frame)
+		copy(h.report[18:23], frame)
+	case HapticReportRumble:
+		h.report[0] = HapticReportRumble
+		h.report[1] = counter & 0x0F
+		copy(h.report[2:7], frame)
+		copy(h.report[7:12], frame)
+	default:
+		return fmt.Errorf("unsupported haptic report 0x%02x", opts.ReportID)
+	}
+	return h.write()
+}
+
+// write sends the prepared report
+func (h *HapticPlayer) write() error {
+	n, err := h.file.Write(h.report[:])
+	if err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	if n != len(h.report) {
+		return fmt.Errorf("short write: %d/%d bytes", n, len(h.report))
+	}
+	return nil
+}
+
+// PlaySimple plays the default haptic pattern
+func (h *HapticPlayer) PlaySimple() error {
+	return h.Play(DefaultHapticPattern, 4*time.Millisecond, 5*time.Second)
+}
@@ -0,0 +1,38 @@
+package procon2
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHeartbeatFile is where the CLI keeps its heartbeat unless told otherwise
+var DefaultHeartbeatFile = filepath.Join(os.TempDir(), "procon2-driver.heartbeat")
+
+// WriteHeartbeat records t in a heartbeat file, replacing it atomically
+func WriteHeartbeat(path string, t time.Time) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(t.UnixNano(), 10)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// CheckHeartbeat returns an error unless the heartbeat file was written within maxAge
+func CheckHeartbeat(path string, maxAge time.Duration) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("no heartbeat, is the driver running? %w", err)
+	}
+	ns, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid heartbeat file %s: %w", path, err)
+	}
+	if age := time.Since(time.Unix(0, ns)); age > maxAge {
+		return fmt.Errorf("last heartbeat %v ago, the driver looks stuck", age.Round(time.Second))
+	}
+	return nil
+}
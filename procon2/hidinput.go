@@ -0,0 +1,928 @@
+package procon2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Calibration constants for joystick normalization
+type JoystickCalibration struct {
+	LXCenter, LXMin, LXMax int
+	LYCenter, LYMin, LYMax int
+	RXCenter, RXMin, RXMax int
+	RYCenter, RYMin, RYMax int
+	Deadzone               int
+
+	// Per-stick deadzones, measured from the jitter at rest. Zero uses Deadzone.
+	LDeadzone, RDeadzone int
+
+	// Per-axis offset added to the center, to fine-tune a stick resting slightly off-center
+	LXTrim, LYTrim, RXTrim, RYTrim int
+}
+
+// StickDeadzones returns the deadzone of each stick, in raw units
+func (c JoystickCalibration) StickDeadzones() (left, right int) {
+	left, right = c.Deadzone, c.Deadzone
+	if c.LDeadzone > 0 {
+		left = c.LDeadzone
+	}
+	if c.RDeadzone > 0 {
+		right = c.RDeadzone
+	}
+	return left, right
+}
+
+// DefaultCalibration provides standard calibration values
+var DefaultCalibration = JoystickCalibration{
+	LXCenter: 2063, LXMin: 294, LXMax: 3735,
+	LYCenter: 2161, LYMin: 512, LYMax: 3733,
+	RXCenter: 2142, RXMin: 407, RXMax: 3628,
+	RYCenter: 2050, RYMin: 368, RYMax: 3854,
+	Deadzone: 50,
+}
+
+// JoystickValues holds normalized joystick positions (-1.0 to 1.0)
+type JoystickValues struct {
+	LX, LY float64 // Left stick
+	RX, RY float64 // Right stick
+}
+
+// ControllerState represents the complete controller input state
+type ControllerState struct {
+	// Face buttons
+	A, B, X, Y bool
+
+	// Shoulder buttons
+	L, R, ZL, ZR bool
+
+	// D-pad
+	DpadUp, DpadDown, DpadLeft, DpadRight bool
+
+	// System buttons
+	Plus, Minus, Home, Capture bool
+
+	// Stick presses
+	LStickPress, RStickPress bool
+
+	// Paddle buttons (if available)
+	PaddleLeft, PaddleRight bool
+
+	// Joystick positions
+	Joysticks JoystickValues
+
+	// Raw 12-bit stick readings, before calibration
+	RawLX, RawLY, RawRX, RawRY int
+
+	// Motion sensors, only valid for report formats that carry them
+	Motion MotionValues
+
+	// Time is when the report was read, from the reader's Clock. With RealClock it carries
+	// a monotonic reading, so time.Since(state.Time) is the latency since then.
+	Time time.Time
+}
+
+// MotionValues is the first IMU sample of a report, in raw sensor units
+type MotionValues struct {
+	AccelX, AccelY, AccelZ int16 // 4096 per G
+	GyroX, GyroY, GyroZ    int16 // About 16 per degree per second
+	Valid                  bool
+}
+
+// HIDReader handles reading from a HID device
+type HIDReader struct {
+	file        *os.File
+	calMu       sync.RWMutex
+	calibration JoystickCalibration
+	buffer      [64]byte
+	stateChan   chan ControllerState
+	errChan     chan error
+	stopChan    chan struct{}
+	done        chan struct{} // Closed once runReadLoop returned
+	closeOnce   sync.Once
+	ready       chan struct{} // Closed once the first full report arrives
+	readyOnce   sync.Once
+	lastReport  atomic.Int64 // UnixNano of the last report
+	lastFresh   atomic.Int64 // UnixNano of the last report whose content changed
+	fullID      atomic.Int32 // ID of the last full report, -1 before the first one
+	prefixed    atomic.Bool  // The last report came with a leading 0x00 byte
+	prevReport  []byte       // Guarded by rawMu
+	rawMu       sync.Mutex
+	driftMu     sync.Mutex
+	drift       DriftOptions
+	stickClamp  StickClamp
+	rumbleOff   atomic.Bool
+	leftDrift   driftCompensator
+	rightDrift  driftCompensator
+	battery     batteryHistory
+	profile     *ModelProfile
+	clock       Clock
+	debugData   []byte
+	debugStats  []ByteStats
+}
+
+// HIDReaderOptions tunes a HIDReader
+type HIDReaderOptions struct {
+	// StartupDrain discards reports until the first full one, for at most this long,
+	// so stale status frames sent right after init are never forwarded as input.
+	// Past it every report is parsed. Zero disables the drain.
+	StartupDrain time.Duration
+
+	// Profile decodes the reports, ProController2Profile when nil
+	Profile *ModelProfile
+
+	// Clock times reports and timeouts, RealClock when nil
+	Clock Clock
+
+	// StickClamp bounds the normalized stick values, StickClampSquare by default
+	StickClamp StickClamp
+
+	// NoRumble leaves the rumble data of the init subcommands zeroed, see SetRumbleEnabled
+	NoRumble bool
+}
+
+// DefaultHIDReaderOptions is what NewHIDReader uses
+var DefaultHIDReaderOptions = HIDReaderOptions{
+	StartupDrain: 500 * time.Millisecond,
+}
+
+// NewHIDReader opens a HID device for reading
+func NewHIDReader(hidPath string, cal JoystickCalibration) (*HIDReader, error) {
+	return NewHIDReaderWithOptions(hidPath, cal, DefaultHIDReaderOptions)
+}
+
+// NewHIDReaderWithOptions is NewHIDReader with chosen options
+func NewHIDReaderWithOptions(hidPath string, cal JoystickCalibration, opts HIDReaderOptions) (*HIDReader, error) {
+	// No O_SYNC: it only concerns writes, and hidraw writes already complete before returning
+	f, err := os.OpenFile(hidPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open hidraw: %w", err)
+	}
+
+	reader := newHIDReader(f, cal, opts)
+
+	// Send initialization commands
+	if err := reader.sendInitCommands(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("init commands failed: %w", err)
+	}
+
+	go reader.runReadLoop(reader.clock.Now().Add(opts.StartupDrain))
+
+	return reader, nil
+}
+
+// newHIDReader sets up a reader of f, whose read loop still has to be started
+func newHIDReader(f *os.File, cal JoystickCalibration, opts HIDReaderOptions) *HIDReader {
+	reader := &HIDReader{
+		file:        f,
+		calibration: cal,
+		stateChan:   make(chan ControllerState, 1),
+		errChan:     make(chan error, 1),
+		stopChan:    make(chan struct{}),
+		done:        make(chan struct{}),
+		ready:       make(chan struct{}),
+		debugData:   make([]byte, 200*64),
+		debugStats:  make([]ByteStats, 64),
+		profile:     opts.Profile,
+		clock:       clockOrReal(opts.Clock),
+		stickClamp:  opts.StickClamp,
+	}
+	if reader.profile == nil {
+		reader.profile = ProController2Profile
+	}
+	reader.rumbleOff.Store(opts.NoRumble)
+	reader.fullID.Store(-1)
+	return reader
+}
+
+// runReadLoop is the ONLY goroutine that reads from the file. Until drainUntil,
+// reports are only forwarded once a full one arrived.
+func (r *HIDReader) runReadLoop(drainUntil time.Time) {
+	defer close(r.done)
+	draining := true
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		default:
+			n, err := r.file.Read(r.buffer[:])
+			if err != nil {
+				// A pending short report error must not hold up the read error
+				select {
+				case <-r.errChan:
+				default:
+				}
+				r.errChan <- err
+				return
+			}
+			report := stripReportPrefix(r.buffer[:n])
+			r.prefixed.Store(len(report) != n)
+			now := r.clock.Now()
+			r.stamp(report, now)
+			if sample, ok := parseBattery(report, now); ok {
+				r.battery.record(sample)
+			}
+			if len(report) == 0 {
+				continue
+			}
+			if need := minReportLength(report[0]); len(report) < need {
+				if _, full := sticksAt(report[0]); full {
+					// Parsing it would center the sticks it lacks, report it instead
+					select {
+					case r.errChan <- &ShortReportError{ID: report[0], Len: len(report), Min: need}:
+					default:
+					}
+				}
+				continue
+			}
+			if _, full := sticksAt(report[0]); full {
+				r.fullID.Store(int32(report[0]))
+				r.readyOnce.Do(func() { close(r.ready) })
+				draining = false
+			}
+			if draining && now.Before(drainUntil) {
+				continue
+			}
+			draining = false
+			state := r.parseReport(report)
+			state.Time = now
+			// Non-blocking send: always keep the stateChan updated with the LATEST report
+			select {
+			case r.stateChan <- state:
+			default:
+				<-r.stateChan // Drain old state
+				r.stateChan <- state
+			}
+		}
+	}
+}
+
+// readerStopTimeout bounds how long Close waits for the read loop to notice the file closed
+const readerStopTimeout = time.Second
+
+// Close closes the HID device and waits for the read loop to stop. Calling it again does nothing.
+func (r *HIDReader) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.stopChan)
+		if r.file != nil {
+			err = r.file.Close()
+		}
+		t := r.clock.NewTimer(readerStopTimeout)
+		defer t.Stop()
+		select {
+		case <-r.done:
+		case <-t.Chan():
+			err = errors.Join(err, errors.New("read loop did not stop"))
+		}
+	})
+	return err
+}
+
+// ReadState now just looks at the channel (no goroutine spawning!)
+func (r *HIDReader) ReadState() (ControllerState, error) {
+	select {
+	case err := <-r.errChan:
+		return ControllerState{}, err
+	case state := <-r.stateChan:
+		return state, nil
+	}
+}
+
+// stamp records the arrival of a report. Full reports carry a timer byte, so even an
+// idle controller never sends the same report twice in a row while it is alive.
+func (r *HIDReader) stamp(report []byte, now time.Time) {
+	r.lastReport.Store(now.UnixNano())
+	r.rawMu.Lock()
+	defer r.rawMu.Unlock()
+	if !bytes.Equal(report, r.prevReport) {
+		r.lastFresh.Store(now.UnixNano())
+		r.prevReport = append(r.prevReport[:0], report...)
+	}
+}
+
+// ReportFormat tells how the reader parses the reports it gets, for debugging clones
+type ReportFormat struct {
+	ID         int  // Report ID of the last full report, -1 before the first one
+	LeftStick  int  // Byte offset of the left stick, -1 when unknown
+	RightStick int  // Byte offset of the right stick, -1 when unknown
+	IMU        int  // Byte offset of the first IMU sample, -1 when the format has none
+	Battery    int  // Byte offset of the battery status, -1 when the format has none or it is unknown
+	MinLength  int  // Shorter reports of this format are dropped, -1 before the first one
+	Prefixed   bool // Reports come with a leading 0x00 byte that is stripped before parsing
+	Profile    string
+}
+
+func (f ReportFormat) String() string {
+	if f.ID < 0 {
+		return fmt.Sprintf("none yet (%s)", f.Profile)
+	}
+	s := fmt.Sprintf("0x%02x sticks@%d,%d min=%d", f.ID, f.LeftStick, f.RightStick, f.MinLength)
+	if f.IMU >= 0 {
+		s += fmt.Sprintf(" imu@%d", f.IMU)
+	}
+	if f.Battery >= 0 {
+		s += fmt.Sprintf(" battery@%d", f.Battery)
+	}
+	if f.Prefixed {
+		s += " prefix-stripped"
+	}
+	return s + fmt.Sprintf(" (%s)", f.Profile)
+}
+
+// ReportFormat returns the report format the reader settled on
+func (r *HIDReader) ReportFormat() ReportFormat {
+	f := ReportFormat{ID: int(r.fullID.Load()), LeftStick: -1, RightStick: -1, IMU: -1, Battery: -1, MinLength: -1,
+		Prefixed: r.prefixed.Load(), Profile: r.profile.Name}
+	if f.ID < 0 {
+		return f
+	}
+	f.MinLength = minReportLength(byte(f.ID))
+	if offsets, ok := sticksAt(byte(f.ID)); ok {
+		f.LeftStick, f.RightStick = offsets[0], offsets[1]
+	}
+	if off, ok := imuOffsets[byte(f.ID)]; ok {
+		f.IMU = off
+	}
+	if off, ok := batteryOffsets[byte(f.ID)]; ok {
+		f.Battery = off
+	}
+	return f
+}
+
+// LastRawReport returns a copy of the last report, without its prefix, or nil before the first one
+func (r *HIDReader) LastRawReport() []byte {
+	r.rawMu.Lock()
+	defer r.rawMu.Unlock()
+	if r.prevReport == nil {
+		return nil
+	}
+	return append([]byte(nil), r.prevReport...)
+}
+
+// BatteryHistory returns the battery samples recorded so far, oldest first
+func (r *HIDReader) BatteryHistory() []BatterySample {
+	return r.battery.snapshot()
+}
+
+// LastReport returns when the last report arrived, zero before the first one
+func (r *HIDReader) LastReport() time.Time {
+	return unixNanoTime(r.lastReport.Load())
+}
+
+// LastFreshReport returns when a report with new content last arrived, zero before the first one
+func (r *HIDReader) LastFreshReport() time.Time {
+	return unixNanoTime(r.lastFresh.Load())
+}
+
+// Stalled reports whether the controller is frozen: no report with new content arrived
+// within window, either because reports stopped or because they repeat verbatim.
+// It is false until the first report.
+func (r *HIDReader) Stalled(window time.Duration) bool {
+	fresh := r.LastFreshReport()
+	return !fresh.IsZero() && r.clock.Now().Sub(fresh) > window
+}
+
+func unixNanoTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// ReadStateTimeout is now extremely cheap to call
+func (r *HIDReader) ReadStateTimeout(timeout time.Duration) (ControllerState, error) {
+	select {
+	case err := <-r.errChan:
+		return ControllerState{}, err
+	case state := <-r.stateChan:
+		return state, nil
+	default:
+	}
+	t := r.clock.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case err := <-r.errChan:
+		return ControllerState{}, err
+	case state := <-r.stateChan:
+		return state, nil
+	case <-t.Chan():
+		return ControllerState{}, errors.New("read timeout")
+	}
+}
+
+// Calibration returns the calibration currently applied to the sticks
+func (r *HIDReader) Calibration() JoystickCalibration {
+	r.calMu.RLock()
+	defer r.calMu.RUnlock()
+	return r.calibration
+}
+
+// SetCalibration replaces the calibration used to normalize the sticks
+func (r *HIDReader) SetCalibration(cal JoystickCalibration) {
+	r.calMu.Lock()
+	defer r.calMu.Unlock()
+	r.calibration = cal
+}
+
+// SetDriftCompensation changes the anti-drift recentering at runtime, a zero Rate
+// disables it and drops the corrections made so far
+func (r *HIDReader) SetDriftCompensation(opts DriftOptions) {
+	r.driftMu.Lock()
+	defer r.driftMu.Unlock()
+	r.drift = opts
+	if opts.Rate <= 0 {
+		r.leftDrift, r.rightDrift = driftCompensator{}, driftCompensator{}
+	}
+}
+
+// DriftCorrection returns the center shift currently applied to each axis, in raw units
+func (r *HIDReader) DriftCorrection() (lx, ly, rx, ry float64) {
+	r.driftMu.Lock()
+	defer r.driftMu.Unlock()
+	return r.leftDrift.x, r.leftDrift.y, r.rightDrift.x, r.rightDrift.y
+}
+
+// SetRumbleEnabled decides whether the init subcommands the reader sends, e.g. on
+// Reinit, carry rumble data, like Controller.SetRumbleEnabled
+func (r *HIDReader) SetRumbleEnabled(on bool) {
+	r.rumbleOff.Store(!on)
+}
+
+// SetTrim changes the per-axis center trim without redoing a full calibration
+func (r *HIDReader) SetTrim(lx, ly, rx, ry int) {
+	r.calMu.Lock()
+	defer r.calMu.Unlock()
+	r.calibration.LXTrim, r.calibration.LYTrim = lx, ly
+	r.calibration.RXTrim, r.calibration.RYTrim = rx, ry
+}
+
+// WaitReady blocks until the controller sends its first full input report
+func (r *HIDReader) WaitReady(timeout time.Duration) error {
+	t := r.clock.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-r.ready:
+		return nil
+	case <-t.Chan():
+		return errors.New("no full input report received")
+	}
+}
+
+// Reinit re-sends the hidraw init commands, e.g. to a controller that fell out of
+// full-report mode. Reading goes on meanwhile.
+func (r *HIDReader) Reinit() error {
+	if err := r.sendInitCommands(); err != nil {
+		return fmt.Errorf("init commands failed: %w", err)
+	}
+	return nil
+}
+
+// DebugReport captures and analyzes HID reports
+func (r *HIDReader) DebugReport(numReports int) (*HIDDebugInfo, error) {
+	requiredSize := numReports * 64
+	if len(r.debugData) < requiredSize {
+		r.debugData = make([]byte, requiredSize)
+	}
+
+	// Reset stats for a fresh run
+	for i := range r.debugStats {
+		r.debugStats[i] = ByteStats{}
+	}
+
+	reports := make([][]byte, numReports)
+
+	for i := 0; i < numReports; i++ {
+		n, err := r.file.Read(r.buffer[:])
+		if err != nil {
+			return nil, fmt.Errorf("read error at report %d: %w", i, err)
+		}
+
+		// Use a slice of the internal debugData block
+		start := i * 64
+		report := r.debugData[start : start+n]
+		copy(report, r.buffer[:n])
+		reports[i] = report
+
+		// Update stats using the internal debugStats slice
+		for j := 0; j < n && j < 64; j++ {
+			s := &r.debugStats[j]
+			val := report[j]
+			if !s.Seen {
+				s.Min, s.Max, s.Seen = val, val, true
+			} else {
+				if val < s.Min {
+					s.Min = val
+				}
+				if val > s.Max {
+					s.Max = val
+				}
+			}
+			if i > 0 && val != reports[0][j] {
+				s.Changes++
+			}
+		}
+	}
+
+	return &HIDDebugInfo{
+		Reports: reports,
+		Stats:   r.debugStats,
+	}, nil
+}
+
+// HIDDebugInfo contains debug statistics
+type HIDDebugInfo struct {
+	Reports [][]byte
+	Stats   []ByteStats
+}
+
+// ByteStats tracks statistics for a byte position
+type ByteStats struct {
+	Seen    bool
+	Min     byte
+	Max     byte
+	Changes int
+}
+
+// Internal methods
+
+func (r *HIDReader) sendInitCommands() error {
+	packetNum := byte(0)
+
+	// Set input mode to 0x30 (full controller state)
+	if _, err := r.file.Write(r.subcommand(packetNum, 0x03, 0x30)); err != nil {
+		return err
+	}
+	packetNum++
+	r.clock.Sleep(100 * time.Millisecond)
+
+	// Set frequency
+	if _, err := r.file.Write(r.subcommand(packetNum, 0x03, 0x31)); err != nil {
+		return err
+	}
+	r.clock.Sleep(100 * time.Millisecond)
+
+	return nil
+}
+
+// subcommand builds a subcommand output report as the profile lays it out
+func (r *HIDReader) subcommand(packetNum, subcmd byte, data ...byte) []byte {
+	msg := []byte{r.profile.SubcommandReport, packetNum}
+	if r.rumbleOff.Load() {
+		msg = append(msg, make([]byte, len(r.profile.RumblePrefix))...)
+	} else {
+		msg = append(msg, r.profile.RumblePrefix[:]...)
+	}
+	msg = append(msg, subcmd)
+	return append(msg, data...)
+}
+
+func (r *HIDReader) parseReport(rep []byte) ControllerState {
+	state := ControllerState{}
+
+	// Parse buttons
+	r.profile.parseButtons(rep, &state)
+
+	// Parse joysticks
+	if len(rep) > 0 {
+		reportID := rep[0]
+		r.parseJoysticks(&state, rep, reportID)
+		state.Motion = parseMotion(rep, reportID)
+	}
+
+	return state
+}
+
+// imuOffsets gives where the first IMU sample starts, for report formats that carry one.
+// Where the Pro Controller 2 puts them in its 0x09 reports is not known yet.
+var imuOffsets = map[byte]int{
+	0x30: 13,
+}
+
+// parseMotion reads the accelerometer then gyroscope axes, as little endian int16
+func parseMotion(data []byte, reportID byte) MotionValues {
+	off, ok := imuOffsets[reportID]
+	if !ok || len(data) < off+12 {
+		return MotionValues{}
+	}
+	v := func(i int) int16 {
+		return int16(binary.LittleEndian.Uint16(data[off+2*i:]))
+	}
+	return MotionValues{
+		AccelX: v(0), AccelY: v(1), AccelZ: v(2),
+		GyroX: v(3), GyroY: v(4), GyroZ: v(5),
+		Valid: true,
+	}
+}
+
+// parseJoysticks fills the raw and normalized stick values of state
+func (r *HIDReader) parseJoysticks(state *ControllerState, data []byte, reportID byte) {
+	vals := &state.Joysticks
+
+	r.calMu.RLock()
+	cal := r.calibration
+	r.calMu.RUnlock()
+	lDead, rDead := cal.StickDeadzones()
+
+	// Get raw 12-bit values
+	lxRaw, lyRaw, lOK := getStickValues(data, true, reportID)
+	rxRaw, ryRaw, rOK := getStickValues(data, false, reportID)
+
+	r.driftMu.Lock()
+	defer r.driftMu.Unlock()
+	now := r.clock.Now()
+
+	// Normalize
+	if lOK {
+		state.RawLX, state.RawLY = lxRaw, lyRaw
+		cx, cy := cal.LXCenter+cal.LXTrim, cal.LYCenter+cal.LYTrim
+		vals.LX = normalizeAxis(lxRaw, cx+int(math.Round(r.leftDrift.x)), cal.LXMin, cal.LXMax, lDead)
+		vals.LY = normalizeAxis(lyRaw, cy+int(math.Round(r.leftDrift.y)), cal.LYMin, cal.LYMax, lDead)
+		if r.drift.Rate > 0 {
+			r.leftDrift.update(r.drift, lxRaw, lyRaw, cx, cy, vals.LX, vals.LY, now)
+		}
+	}
+
+	if rOK {
+		state.RawRX, state.RawRY = rxRaw, ryRaw
+		cx, cy := cal.RXCenter+cal.RXTrim, cal.RYCenter+cal.RYTrim
+		vals.RX = normalizeAxis(rxRaw, cx+int(math.Round(r.rightDrift.x)), cal.RXMin, cal.RXMax, rDead)
+		vals.RY = normalizeAxis(ryRaw, cy+int(math.Round(r.rightDrift.y)), cal.RYMin, cal.RYMax, rDead)
+		if r.drift.Rate > 0 {
+			r.rightDrift.update(r.drift, rxRaw, ryRaw, cx, cy, vals.RX, vals.RY, now)
+		}
+	}
+	vals.LX, vals.LY = r.stickClamp.apply(vals.LX, vals.LY)
+	vals.RX, vals.RY = r.stickClamp.apply(vals.RX, vals.RY)
+}
+
+// normalizeAxis rescales a raw axis to -1.0 - 1.0 over its calibrated range. Values past
+// the range go beyond, the reader's StickClamp bounds them.
+func normalizeAxis(rawValue int, center, minVal, maxVal, deadzone int) float64 {
+	// Apply deadzone, the rest of the range is rescaled so the output ramps up from 0
+	// at its edge instead of jumping
+	offset := rawValue - center
+	if abs(offset) < deadzone {
+		return 0.0
+	}
+	deadzone = max(deadzone, 0)
+
+	if offset > 0 {
+		rangeMax := maxVal - center - deadzone
+		if rangeMax <= 0 {
+			return 0.0
+		}
+		return float64(offset-deadzone) / float64(rangeMax)
+	}
+
+	if offset < 0 {
+		rangeMin := center - minVal - deadzone
+		if rangeMin <= 0 {
+			return 0.0
+		}
+		return float64(offset+deadzone) / float64(rangeMin)
+	}
+
+	return 0.0
+}
+
+// stickOffsets maps each supported report ID to the byte offsets of the left and right stick.
+// The standard full report (0x30) places 3 bytes per stick right after the 3 button bytes.
+var stickOffsets = map[byte][2]int{
+	0x30: {6, 9},
+}
+
+// assumedLayouts maps the report IDs whose stick layout was never checked against a
+// captured report to the ID they are parsed like. The Pro Controller 2 USB report (0x09)
+// is assumed to match 0x30 up to the sticks, as the driver always read it. Drop its entry
+// for a stickOffsets one once a capture confirms or corrects the offsets.
+var assumedLayouts = map[byte]byte{
+	0x09: 0x30,
+}
+
+// layoutOf returns the report ID whose stick layout reports of this ID are parsed with
+func layoutOf(id byte) byte {
+	if known, ok := assumedLayouts[id]; ok {
+		return known
+	}
+	return id
+}
+
+// sticksAt returns the stick offsets of a report ID, ok is false for reports without sticks
+func sticksAt(id byte) (offsets [2]int, ok bool) {
+	offsets, ok = stickOffsets[layoutOf(id)]
+	return offsets, ok
+}
+
+// reportMinLengths is the shortest report of each full format that still carries
+// both sticks. Optional trailing data such as the IMU samples is checked when parsed.
+var reportMinLengths = map[byte]int{
+	0x30: 12,
+}
+
+// minReportLength returns the shortest report of the given ID that gets parsed.
+// Other reports only need their button bytes.
+func minReportLength(id byte) int {
+	if n, ok := reportMinLengths[layoutOf(id)]; ok {
+		return n
+	}
+	return 6
+}
+
+// ErrShortReport is matched by the errors about truncated full reports
+var ErrShortReport = errors.New("input report too short")
+
+// ShortReportError is returned by ReadState for a full report shorter than its format needs.
+// The report is dropped, reading goes on.
+type ShortReportError struct {
+	ID  byte
+	Len int // Length of the report, without its prefix
+	Min int // Length its format needs
+}
+
+func (e *ShortReportError) Error() string {
+	return fmt.Sprintf("report 0x%02x is %d bytes, its format needs %d", e.ID, e.Len, e.Min)
+}
+
+func (e *ShortReportError) Unwrap() error {
+	return ErrShortReport
+}
+
+// stripReportPrefix drops the leading 0x00 byte some kernels put in front of numbered reports,
+// so that offsets always start at the real report ID
+func stripReportPrefix(rep []byte) []byte {
+	if len(rep) > 1 && rep[0] == 0x00 {
+		if _, known := sticksAt(rep[1]); known {
+			return rep[1:]
+		}
+	}
+	return rep
+}
+
+// getStickValues decodes 12-bit joystick values from HID report. ok is false when the
+// report format has no sticks or the report is too short; 0 is a valid reading.
+func getStickValues(data []byte, isLeft bool, reportID byte) (x, y int, ok bool) {
+	offsets, known := sticksAt(reportID)
+	if !known {
+		return 0, 0, false
+	}
+
+	offset := offsets[1]
+	if isLeft {
+		offset = offsets[0]
+	}
+
+	if len(data) < offset+3 {
+		return 0, 0, false
+	}
+
+	b0 := data[offset]
+	b1 := data[offset+1]
+	b2 := data[offset+2]
+
+	// X is lower 12 bits, Y is upper 12 bits
+	x = int(b0) | (int(b1&0x0F) << 8)
+	y = (int(b1&0xF0) >> 4) | (int(b2) << 4)
+
+	return x, y, true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Helper methods for ControllerState
+
+// ButtonsEqual checks if button states are equal (ignoring joysticks)
+func (s ControllerState) ButtonsEqual(o ControllerState) bool {
+	return s.A == o.A && s.B == o.B && s.X == o.X && s.Y == o.Y &&
+		s.L == o.L && s.R == o.R && s.ZL == o.ZL && s.ZR == o.ZR &&
+		s.DpadUp == o.DpadUp && s.DpadDown == o.DpadDown &&
+		s.DpadLeft == o.DpadLeft && s.DpadRight == o.DpadRight &&
+		s.Plus == o.Plus && s.Minus == o.Minus &&
+		s.Home == o.Home && s.Capture == o.Capture &&
+		s.LStickPress == o.LStickPress && s.RStickPress == o.RStickPress &&
+		s.PaddleLeft == o.PaddleLeft && s.PaddleRight == o.PaddleRight
+}
+
+// SameInput checks if two states carry the same input, whenever they were read
+func (s ControllerState) SameInput(o ControllerState) bool {
+	s.Time, o.Time = time.Time{}, time.Time{}
+	return s == o
+}
+
+// JoysticksChanged checks if joysticks moved significantly
+func (s ControllerState) JoysticksChanged(o ControllerState, threshold float64) bool {
+	return math.Abs(s.Joysticks.LX-o.Joysticks.LX) > threshold ||
+		math.Abs(s.Joysticks.LY-o.Joysticks.LY) > threshold ||
+		math.Abs(s.Joysticks.RX-o.Joysticks.RX) > threshold ||
+		math.Abs(s.Joysticks.RY-o.Joysticks.RY) > threshold
+}
+
+// HasInput reports whether any button is pressed or any stick is pushed further than
+// deadzone (0.0 - 1.0) from its center
+func (s ControllerState) HasInput(deadzone float64) bool {
+	for _, b := range AllButtons {
+		if s.Pressed(b) {
+			return true
+		}
+	}
+	j := s.Joysticks
+	return math.Hypot(j.LX, j.LY) > deadzone || math.Hypot(j.RX, j.RY) > deadzone
+}
+
+// GetPressedButtons returns a list of pressed button names
+func (s ControllerState) GetPressedButtons() []string {
+	var pressed []string
+
+	if s.A {
+		pressed = append(pressed, "A")
+	}
+	if s.B {
+		pressed = append(pressed, "B")
+	}
+	if s.X {
+		pressed = append(pressed, "X")
+	}
+	if s.Y {
+		pressed = append(pressed, "Y")
+	}
+	if s.L {
+		pressed = append(pressed, "L")
+	}
+	if s.R {
+		pressed = append(pressed, "R")
+	}
+	if s.ZL {
+		pressed = append(pressed, "ZL")
+	}
+	if s.ZR {
+		pressed = append(pressed, "ZR")
+	}
+	if s.DpadUp {
+		pressed = append(pressed, "↑")
+	}
+	if s.DpadDown {
+		pressed = append(pressed, "↓")
+	}
+	if s.DpadLeft {
+		pressed = append(pressed, "←")
+	}
+	if s.DpadRight {
+		pressed = append(pressed, "→")
+	}
+	if s.Plus {
+		pressed = append(pressed, "+")
+	}
+	if s.Minus {
+		pressed = append(pressed, "-")
+	}
+	if s.Home {
+		pressed = append(pressed, "HOME")
+	}
+	if s.Capture {
+		pressed = append(pressed, "CAPTURE")
+	}
+	if s.LStickPress {
+		pressed = append(pressed, "L-STICK")
+	}
+	if s.RStickPress {
+		pressed = append(pressed, "R-STICK")
+	}
+	if s.PaddleLeft {
+		pressed = append(pressed, "PADDLE-L")
+	}
+	if s.PaddleRight {
+		pressed = append(pressed, "PADDLE-R")
+	}
+
+	return pressed
+}
+
+// GetStickDirection returns direction string for a stick, CENTER when the virtual gamepad
+// would forward it centered with this normalized deadzone
+func GetStickDirection(x, y float64, deadzone float64) string {
+	if applyDeadzone(x, deadzone) == 0 && applyDeadzone(y, deadzone) == 0 {
+		return "CENTER"
+	}
+	if math.Abs(x) > math.Abs(y) {
+		if x > 0 {
+			return "RIGHT"
+		}
+		return "LEFT"
+	}
+	if y > 0 {
+		return "DOWN"
+	}
+	return "UP"
+}
@@ -0,0 +1,163 @@
+package procon2
+
+import (
+	"math"
+	"testing"
+)
+
+// near reports whether two stick values match, up to float rounding
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// putStick writes a 12-bit stick reading at off, packed like full reports carry it
+func putStick(report []byte, off, x, y int) {
+	report[off] = byte(x)
+	report[off+1] = byte(x>>8&0x0F) | byte(y&0x0F)<<4
+	report[off+2] = byte(y >> 4)
+}
+
+func TestDecodeReportSticks(t *testing.T) {
+	// Full reports with A held and sticks near rest. The 0x09 one is laid out as
+	// assumedLayouts says, not captured from a controller.
+	switch2 := []byte{
+		0x09, 0x7c, 0x23, 0x02, 0x00, 0x00, 0x12, 0x18, 0x87, 0x5e, 0xf8, 0x80,
+		0x00, 0x38, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	switch1 := []byte{
+		0x30, 0x4a, 0x91, 0x08, 0x80, 0x00, 0xf8, 0xd7, 0x7a, 0x22, 0xc8, 0x7b,
+		0x0c, 0x9a, 0xfe, 0x2e, 0x00, 0xd6, 0x0f, 0x0a, 0x00, 0xf6, 0xff, 0x03,
+		0x00, 0xa0, 0xfe, 0x2f, 0x00, 0xd4, 0x0f, 0x0a, 0x00, 0xf5, 0xff, 0x03,
+		0x00, 0xa2, 0xfe, 0x2f, 0x00, 0xd7, 0x0f, 0x0a, 0x00, 0xf6, 0xff, 0x03,
+		0x00,
+	}
+
+	tests := []struct {
+		name           string
+		report         []byte
+		lx, ly, rx, ry int
+	}{
+		{"0x09, assumed layout", switch2, 2066, 2161, 2142, 2063},
+		{"0x09 with prefix, assumed layout", append([]byte{0x00}, switch2...), 2066, 2161, 2142, 2063},
+		{"0x30", switch1, 2040, 1965, 2082, 1980},
+	}
+	for _, tt := range tests {
+		report := stripReportPrefix(tt.report)
+		lx, ly, _ := getStickValues(report, true, report[0])
+		rx, ry, _ := getStickValues(report, false, report[0])
+		if lx != tt.lx || ly != tt.ly || rx != tt.rx || ry != tt.ry {
+			t.Errorf("%s: raw sticks (%d, %d) (%d, %d), want (%d, %d) (%d, %d)", tt.name,
+				lx, ly, rx, ry, tt.lx, tt.ly, tt.rx, tt.ry)
+		}
+	}
+}
+
+func TestAssumedLayouts(t *testing.T) {
+	for id, known := range assumedLayouts {
+		if _, ok := stickOffsets[id]; ok {
+			t.Errorf("0x%02x is both verified and assumed", id)
+		}
+		if _, ok := stickOffsets[known]; !ok {
+			t.Errorf("0x%02x is assumed to be laid out like 0x%02x, which has no stick offsets", id, known)
+			continue
+		}
+
+		// Until a capture says otherwise, both IDs read the same bytes
+		report := make([]byte, 64)
+		putStick(report, 6, 1000, 3000)
+		putStick(report, 9, 2500, 500)
+		for _, left := range []bool{true, false} {
+			wx, wy, _ := getStickValues(report, left, known)
+			x, y, ok := getStickValues(report, left, id)
+			if !ok || x != wx || y != wy {
+				t.Errorf("0x%02x left=%v: got (%d, %d, %v), want (%d, %d) like 0x%02x", id, left, x, y, ok, wx, wy, known)
+			}
+		}
+		if got, want := minReportLength(id), minReportLength(known); got != want {
+			t.Errorf("minReportLength(0x%02x) = %d, want %d like 0x%02x", id, got, want, known)
+		}
+	}
+}
+
+func TestNormalizeAxisDeadzoneEdge(t *testing.T) {
+	tests := []struct {
+		name                       string
+		center, min, max, deadzone int
+	}{
+		{"symmetric", 2048, 48, 4048, 100},
+		{"asymmetric, short max side", 2063, 294, 3000, 50},
+		{"asymmetric, short min side", 2161, 1500, 3733, 80},
+		{"no deadzone", 2000, 500, 3500, 0},
+	}
+	for _, tt := range tests {
+		norm := func(raw int) float64 {
+			return normalizeAxis(raw, tt.center, tt.min, tt.max, tt.deadzone)
+		}
+		up := float64(tt.max - tt.center - tt.deadzone)
+		down := float64(tt.center - tt.min - tt.deadzone)
+
+		// The output is 0 up to the edge on both sides, then ramps up by one step
+		edges := []struct {
+			raw  int
+			want float64
+		}{
+			{tt.center, 0},
+			{tt.center + tt.deadzone, 0},
+			{tt.center - tt.deadzone, 0},
+			{tt.center + tt.deadzone + 1, 1 / up},
+			{tt.center - tt.deadzone - 1, -1 / down},
+			{tt.max, 1},
+			{tt.min, -1},
+		}
+		for _, e := range edges {
+			if got := norm(e.raw); !near(got, e.want) {
+				t.Errorf("%s: normalizeAxis(%d) = %v, want %v", tt.name, e.raw, got, e.want)
+			}
+		}
+
+		// No jump anywhere: neighbouring readings differ by at most one step of their side
+		maxStep := 1/math.Min(up, down) + 1e-12
+		for raw := tt.min; raw < tt.max; raw++ {
+			if d := math.Abs(norm(raw+1) - norm(raw)); d > maxStep {
+				t.Fatalf("%s: jump of %v between raw %d and %d", tt.name, d, raw, raw+1)
+			}
+		}
+	}
+}
+
+func TestGetStickValues(t *testing.T) {
+	zeroSticks := make([]byte, 64)
+	zeroSticks[0] = 0x09
+	zeroSticks[3] = 0x02 // A pressed, the stick bytes stay 0
+
+	short := make([]byte, 10) // Ends inside the right stick
+	short[0] = 0x09
+
+	unknown := make([]byte, 64)
+	unknown[0] = 0x21
+	putStick(unknown, 6, 2048, 2048)
+
+	tests := []struct {
+		name   string
+		report []byte
+		left   bool
+		x, y   int
+		ok     bool
+	}{
+		{"zero reading, left", zeroSticks, true, 0, 0, true},
+		{"zero reading, right", zeroSticks, false, 0, 0, true},
+		{"short report, right", short, false, 0, 0, false},
+		{"unknown report ID", unknown, true, 0, 0, false},
+	}
+	for _, tt := range tests {
+		x, y, ok := getStickValues(tt.report, tt.left, tt.report[0])
+		if x != tt.x || y != tt.y || ok != tt.ok {
+			t.Errorf("%s: got (%d, %d, %v), want (%d, %d, %v)", tt.name, x, y, ok, tt.x, tt.y, tt.ok)
+		}
+	}
+
+	// The left stick still fits in the short report
+	if _, _, ok := getStickValues(short, true, 0x09); !ok {
+		t.Error("left stick of a report ending in the right stick: ok = false")
+	}
+}
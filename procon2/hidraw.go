@@ -0,0 +1,223 @@
+package procon2
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// GetHidrawForUSB finds the hidraw path for a specific USB Bus and Device Address
+func GetHidrawForUSB(targetBus int, targetAddr int) (string, error) {
+	base := "/sys/class/hidraw"
+	entries, err := ioutil.ReadDir(base)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", base, err)
+	}
+
+	var firstErr error // Nodes still being set up right after plug-in fail this way
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "hidraw") {
+			continue
+		}
+
+		// Check if this hidraw node belongs to the target USB device
+		// /sys/class/hidraw/hidrawX/device -> ... -> USB Device
+		hidPath := filepath.Join(base, entry.Name(), "device")
+		match, err := matchesUSBDevice(hidPath, targetBus, targetAddr)
+		if match {
+			return "/dev/" + entry.Name(), nil
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+	}
+
+	if firstErr != nil {
+		return "", fmt.Errorf("no hidraw device found for USB Bus %d Device %d (%w)", targetBus, targetAddr, firstErr)
+	}
+	return "", fmt.Errorf("no hidraw device found for USB Bus %d Device %d", targetBus, targetAddr)
+}
+
+// hidrawResolveAttempts and hidrawResolveDelay bound how long GetHidrawForUSBWithRetry
+// waits for the hidraw node, which often shows up a few hundred ms after the USB device
+const (
+	hidrawResolveAttempts = 10
+	hidrawResolveDelay    = 100 * time.Millisecond
+)
+
+// GetHidrawForUSBWithRetry is GetHidrawForUSB, retrying for about a second while the
+// node is missing, each retry logged to logger
+func GetHidrawForUSBWithRetry(targetBus, targetAddr int, logger Logger) (string, error) {
+	return getHidrawForUSBWithRetry(targetBus, targetAddr, logger, RealClock)
+}
+
+// getHidrawForUSBWithRetry is GetHidrawForUSBWithRetry waiting on clock
+func getHidrawForUSBWithRetry(targetBus, targetAddr int, logger Logger, clock Clock) (string, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		var path string
+		if path, err = GetHidrawForUSB(targetBus, targetAddr); err == nil {
+			return path, nil
+		}
+		if attempt == hidrawResolveAttempts {
+			return "", err
+		}
+		logger.Printf("⏳ No hidraw node yet (%v), retrying %d/%d", err, attempt, hidrawResolveAttempts-1)
+		clock.Sleep(hidrawResolveDelay)
+	}
+}
+
+// hidraw ioctls: HIDIOCGRAWINFO and HIDIOCGRAWUNIQ(64)
+const (
+	hidiocGRawInfo = 0x80084803
+	hidiocGRawUniq = 0x80404808
+)
+
+// hidrawDevinfo mirrors struct hidraw_devinfo
+type hidrawDevinfo struct {
+	bustype         uint32
+	vendor, product uint16
+}
+
+// CheckHidrawNode makes sure the hidraw node at path belongs to a device with these IDs
+// and, when both are known, this serial. Kernels that can't report the serial of a node
+// only get the IDs checked.
+func CheckHidrawNode(path string, vendor, product uint16, serial string) error {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var info hidrawDevinfo
+	if err := ioctlSetup(f.Fd(), hidiocGRawInfo, unsafe.Pointer(&info)); err != nil {
+		return fmt.Errorf("reading the IDs of %s: %w", path, err)
+	}
+	if info.vendor != vendor || info.product != product {
+		return fmt.Errorf("%s is device %04x:%04x, not %04x:%04x", path, info.vendor, info.product, vendor, product)
+	}
+	if serial == "" {
+		return nil
+	}
+	var uniq [64]byte
+	if err := ioctlSetup(f.Fd(), hidiocGRawUniq, unsafe.Pointer(&uniq)); err != nil {
+		return nil
+	}
+	if got := strings.TrimRight(string(uniq[:]), "\x00"); got != "" && got != serial {
+		return fmt.Errorf("%s belongs to controller %q, not %q", path, got, serial)
+	}
+	return nil
+}
+
+// GetEvdevForUSB finds the /dev/input/eventX path for a specific USB Bus/Address
+func GetEvdevForUSB(targetBus int, targetAddr int) (string, error) {
+	base := "/sys/class/input"
+	entries, err := ioutil.ReadDir(base)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", base, err)
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "event") {
+			continue
+		}
+
+		// Check if this input node belongs to the target USB device
+		// /sys/class/input/eventX/device -> ... -> USB Device
+		devPath := filepath.Join(base, entry.Name(), "device")
+		if match, _ := matchesUSBDevice(devPath, targetBus, targetAddr); match {
+			return filepath.Join("/dev/input", entry.Name()), nil
+		}
+	}
+
+	return "", fmt.Errorf("no evdev node found for USB Bus %d Device %d", targetBus, targetAddr)
+}
+
+// matchesUSBDevice walks up the sysfs tree to find if a path belongs to a specific USB Bus/Addr.
+// The error tells why a node could not be checked, e.g. sysfs entries not created yet.
+func matchesUSBDevice(startPath string, targetBus, targetAddr int) (bool, error) {
+	realPath, err := filepath.EvalSymlinks(startPath)
+	if err != nil {
+		return false, err
+	}
+
+	// Walk up the directory tree looking for "busnum" and "devnum" files
+	// We limit the depth to avoid infinite loops, though sysfs is finite
+	dir := realPath
+	for i := 0; i < 6; i++ { // Check up to 6 levels up
+		busFile := filepath.Join(dir, "busnum")
+		devFile := filepath.Join(dir, "devnum")
+
+		if fileExists(busFile) && fileExists(devFile) {
+			bus, err := readIntFile(busFile)
+			if err != nil {
+				return false, err
+			}
+			addr, err := readIntFile(devFile)
+			if err != nil {
+				return false, err
+			}
+			// Found a USB device, whether the numbers match or not
+			return bus == targetBus && addr == targetAddr, nil
+		}
+
+		// Move up
+		dir = filepath.Clean(filepath.Join(dir, ".."))
+		if dir == "/" || dir == "." {
+			break
+		}
+	}
+	return false, nil
+}
+
+func readIntFile(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return false
+	}
+	return !info.IsDir()
+}
+
+// FindVirtualDevices lists the uinput devices whose name starts with prefix, as "inputN: name"
+func FindVirtualDevices(prefix string) ([]string, error) {
+	base := "/sys/class/input"
+	entries, err := ioutil.ReadDir(base)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", base, err)
+	}
+
+	var found []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "input") {
+			continue
+		}
+
+		// uinput devices live under /sys/devices/virtual/input
+		realPath, err := filepath.EvalSymlinks(filepath.Join(base, entry.Name()))
+		if err != nil || !strings.Contains(realPath, "/virtual/") {
+			continue
+		}
+		name, err := ioutil.ReadFile(filepath.Join(base, entry.Name(), "name"))
+		if err != nil {
+			continue
+		}
+		if n := strings.TrimSpace(string(name)); strings.HasPrefix(n, prefix) {
+			found = append(found, fmt.Sprintf("%s: %s", entry.Name(), n))
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,152 @@
+package procon2
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VirtualIdentity is the name and USB IDs a virtual gamepad presents, which games and SDL
+// use to pick a button layout. The name gets " (Player N)" appended.
+type VirtualIdentity struct {
+	Name    string
+	Vendor  uint16
+	Product uint16
+	Version uint16
+}
+
+// IdentityProController presents the gamepad as this driver's Pro Controller
+var IdentityProController = VirtualIdentity{Name: DRIVER_NAME, Vendor: PROCON_VENDOR, Product: 0x2019, Version: 1}
+
+// IdentityXbox360 presents the gamepad as a wired Xbox 360 pad, for games that only
+// know XInput layouts
+var IdentityXbox360 = VirtualIdentity{Name: "Microsoft X-Box 360 pad", Vendor: 0x045e, Product: 0x028e, Version: 0x0114}
+
+// namedIdentities are the identities ParseIdentity accepts by name
+var namedIdentities = map[string]VirtualIdentity{
+	"procon":  IdentityProController,
+	"xbox360": IdentityXbox360,
+}
+
+// ParseIdentity parses "procon", "xbox360" or VID:PID[:NAME] with hexadecimal IDs,
+// e.g. "054c:05c4:Sony DualShock 4"
+func ParseIdentity(spec string) (VirtualIdentity, error) {
+	spec = strings.TrimSpace(spec)
+	if id, ok := namedIdentities[strings.ToLower(spec)]; ok {
+		return id, nil
+	}
+	parts := strings.SplitN(spec, ":", 3)
+	if len(parts) < 2 {
+		return VirtualIdentity{}, fmt.Errorf("unknown identity %q (expected procon, xbox360 or VID:PID[:NAME])", spec)
+	}
+	vendor, err := strconv.ParseUint(parts[0], 16, 16)
+	if err != nil {
+		return VirtualIdentity{}, fmt.Errorf("invalid vendor ID %q", parts[0])
+	}
+	product, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return VirtualIdentity{}, fmt.Errorf("invalid product ID %q", parts[1])
+	}
+	id := VirtualIdentity{Name: DRIVER_NAME, Vendor: uint16(vendor), Product: uint16(product), Version: 1}
+	if len(parts) == 3 && parts[2] != "" {
+		id.Name = parts[2]
+	}
+	return id, nil
+}
+
+// ParseIdentities parses comma separated [SERIAL=]IDENTITY items, see ParseIdentity. An
+// item without a serial sets the identity of every other controller, stored under "".
+func ParseIdentities(spec string) (map[string]VirtualIdentity, error) {
+	ids := make(map[string]VirtualIdentity)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		serial, value, ok := strings.Cut(item, "=")
+		if !ok {
+			serial, value = "", item
+		}
+		id, err := ParseIdentity(value)
+		if err != nil {
+			return nil, err
+		}
+		ids[strings.TrimSpace(serial)] = id
+	}
+	return ids, nil
+}
+
+// SDLMapping returns the SDL_GAMECONTROLLERCONFIG line matching a gamepad created with
+// these options, so SDL games read the buttons right whatever identity it presents
+func (o GamepadOptions) SDLMapping() string {
+	id := o.identity()
+	le := func(v uint16) string { return fmt.Sprintf("%02x%02x0000", v&0xFF, v>>8) }
+	guid := le(busUsb) + le(id.Vendor) + le(id.Product) + le(id.Version)
+
+	// SDL numbers the buttons from BTN_JOYSTICK (0x120) up, then the lower codes
+	codes := o.buttonCodes()
+	sort.Slice(codes, func(i, j int) bool {
+		hi, hj := codes[i] >= btnJoystick, codes[j] >= btnJoystick
+		if hi != hj {
+			return hi
+		}
+		return codes[i] < codes[j]
+	})
+	index := make(map[uint16]int, len(codes))
+	for i, code := range codes {
+		index[code] = i
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s,%s,", guid, id.Name)
+	button := func(name string, code uint16) {
+		if i, ok := index[code]; ok {
+			fmt.Fprintf(&b, "%s:b%d,", name, i)
+		}
+	}
+	// Buttons go out by label (A on BTN_SOUTH, X on BTN_NORTH...), SDL gets them the same way
+	button("a", btnSouth)
+	button("b", btnEast)
+	button("x", btnNorth)
+	button("y", btnWest)
+	button("leftshoulder", btnTL)
+	button("rightshoulder", btnTR)
+	button("lefttrigger", btnTL2)
+	button("righttrigger", btnTR2)
+	button("back", btnSelect)
+	button("start", btnStart)
+	button("guide", btnMode)
+	button("leftstick", btnThumbL)
+	button("rightstick", btnThumbR)
+	if o.Dpad == DpadAsHat {
+		b.WriteString("dpup:h0.1,dpright:h0.2,dpdown:h0.4,dpleft:h0.8,")
+	} else {
+		button("dpup", btnDpadUp)
+		button("dpdown", btnDpadDown)
+		button("dpleft", btnDpadLeft)
+		button("dpright", btnDpadRight)
+	}
+	if o.YUp {
+		// SDL expects up to be negative, ~ inverts the axis
+		b.WriteString("leftx:a0,lefty:a1~,rightx:a2,righty:a3~,platform:Linux,")
+	} else {
+		b.WriteString("leftx:a0,lefty:a1,rightx:a2,righty:a3,platform:Linux,")
+	}
+	return b.String()
+}
+
+// identityFor returns the identity the gamepad of serial presents: its own entry in
+// Config.Identities, the "" entry, the profile's, then IdentityProController
+func (m *Manager) identityFor(serial string, profile *ModelProfile) VirtualIdentity {
+	if id, ok := m.cfg.Identities[serial]; ok && serial != "" {
+		return id
+	}
+	if id, ok := m.cfg.Identities[""]; ok {
+		return id
+	}
+	if profile != nil && profile.Identity != (VirtualIdentity{}) {
+		return profile.Identity
+	}
+	return IdentityProController
+}
@@ -0,0 +1,123 @@
+package procon2
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Linux key codes used by DefaultKeyMap
+const (
+	keyEsc       = 1
+	keyBackspace = 14
+	keyTab       = 15
+	keyEnter     = 28
+	keyLeftShift = 42
+	keySpace     = 57
+	keyUp        = 103
+	keyPageUp    = 104
+	keyLeft      = 105
+	keyRight     = 106
+	keyDown      = 108
+	keyPageDown  = 109
+	keyHomepage  = 172
+	keySysrq     = 99
+)
+
+// DefaultKeyMap maps controller buttons to keys for desktop navigation
+var DefaultKeyMap = map[Button]uint16{
+	ButtonA: keyEnter, ButtonB: keyEsc, ButtonX: keySpace, ButtonY: keyBackspace,
+	ButtonL: keyPageUp, ButtonR: keyPageDown,
+	ButtonUp: keyUp, ButtonDown: keyDown, ButtonLeft: keyLeft, ButtonRight: keyRight,
+	ButtonPlus: keyTab, ButtonMinus: keyLeftShift,
+	ButtonHome: keyHomepage, ButtonCapture: keySysrq,
+}
+
+// VirtualKeyboard forwards buttons as key presses
+type VirtualKeyboard struct {
+	mu     sync.Mutex
+	file   *os.File
+	keyMap map[Button]uint16
+	extra  extraCodes
+	held   map[uint16]bool // Extra codes of the frame being built, reused across frames
+	deviceClock
+}
+
+// NewVirtualKeyboard creates a virtual keyboard using DefaultKeyMap
+func NewVirtualKeyboard(playerNum int) (*VirtualKeyboard, error) {
+	return NewVirtualKeyboardWithBindings(playerNum, nil)
+}
+
+// NewVirtualKeyboardWithBindings is NewVirtualKeyboard also sending the keyboard codes of bindings
+func NewVirtualKeyboardWithBindings(playerNum int, bindings Bindings) (*VirtualKeyboard, error) {
+	keys := make([]uint16, 0, len(DefaultKeyMap))
+	for _, key := range DefaultKeyMap {
+		keys = append(keys, key)
+	}
+	extra := bindings.codes(OutputKeyboard)
+	keys = append(keys, extra.all()...)
+
+	f, err := newUinputDevice(fmt.Sprintf("%s Keyboard (Player %d)", DRIVER_NAME, playerNum), keys, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &VirtualKeyboard{file: f, keyMap: DefaultKeyMap, extra: extra}, nil
+}
+
+func (k *VirtualKeyboard) Update(state ControllerState) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.file == nil {
+		return fmt.Errorf("virtual keyboard closed")
+	}
+	return k.update(state)
+}
+
+func (k *VirtualKeyboard) update(state ControllerState) error {
+	// The kernel drops key events that don't change the key state, so no autorepeat flood
+	var events []inputEvent
+	k.held = k.extra.pressed(state, k.held)
+	held := k.held
+	for _, b := range AllButtons {
+		key, ok := k.keyMap[b]
+		if !ok {
+			continue
+		}
+		pressed := state.Pressed(b)
+		if extra, bound := held[key]; bound {
+			// Also bound to another button, held while either is
+			pressed = pressed || extra
+			delete(held, key)
+		}
+		events = append(events, inputEvent{typ: evKey, code: key, value: boolValue(pressed)})
+	}
+	for key, pressed := range held {
+		events = append(events, inputEvent{typ: evKey, code: key, value: boolValue(pressed)})
+	}
+	events = append(events, inputEvent{typ: evSyn})
+	return writeInputEvents(k.file, events, k.clock)
+}
+
+// boolValue is the value of a key event
+func boolValue(pressed bool) int32 {
+	if pressed {
+		return 1
+	}
+	return 0
+}
+
+func (k *VirtualKeyboard) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.file != nil {
+		k.update(ControllerState{})
+
+		ioctl(k.file.Fd(), uiDevDestroy, 0)
+		err := k.file.Close()
+		k.file = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package procon2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultPlayerLEDs are the LED patterns of players 1 and up, bit 0 being the leftmost LED.
+// Players past the single dots get two dots so they can still tell themselves apart.
+var DefaultPlayerLEDs = []byte{
+	0x01, // ●○○○
+	0x02, // ○●○○
+	0x04, // ○○●○
+	0x08, // ○○○●
+	0x03, // ●●○○
+	0x06, // ○●●○
+	0x0c, // ○○●●
+	0x09, // ●○○●
+}
+
+// PlayerLEDPattern returns the pattern of a player (1-based) from table, or the first one
+// when the table doesn't go that far
+func PlayerLEDPattern(table []byte, playerNum int) byte {
+	if playerNum >= 1 && playerNum <= len(table) {
+		return table[playerNum-1]
+	}
+	if len(table) > 0 {
+		return table[0]
+	}
+	return 0x01
+}
+
+// ParsePlayerLEDs parses a comma separated list of patterns, one per player, written as
+// four 0/1 digits from the leftmost LED, e.g. "1000,0100,0010,0001,1100"
+func ParsePlayerLEDs(spec string) ([]byte, error) {
+	var table []byte
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if len(item) != 4 {
+			return nil, fmt.Errorf("invalid LED pattern %q (expected four 0/1 digits, e.g. 1100)", item)
+		}
+
+		var pattern byte
+		for i, c := range item {
+			switch c {
+			case '1':
+				pattern |= 1 << i
+			case '0':
+			default:
+				return nil, fmt.Errorf("invalid LED pattern %q (expected four 0/1 digits, e.g. 1100)", item)
+			}
+		}
+		table = append(table, pattern)
+	}
+	return table, nil
+}
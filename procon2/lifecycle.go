@@ -0,0 +1,53 @@
+package procon2
+
+import "time"
+
+// ManagerEventType is what happened to a controller
+type ManagerEventType int
+
+const (
+	ControllerConnected    ManagerEventType = iota
+	ControllerDisconnected                  // Reason tells why
+	ControllerError                         // A controller was found but couldn't be started, see Err
+)
+
+func (t ManagerEventType) String() string {
+	switch t {
+	case ControllerConnected:
+		return "connected"
+	case ControllerDisconnected:
+		return "disconnected"
+	case ControllerError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// ManagerEvent is a controller lifecycle change
+type ManagerEvent struct {
+	Type     ManagerEventType
+	Slot     int // 0 to 3 (Player 1-4)
+	UniqueID string
+	Serial   string
+	Reason   DisconnectReason // Only for ControllerDisconnected
+	Err      error            // Only for ControllerError
+	Time     time.Time
+}
+
+// managerEventBuffer is how many events wait for a slow consumer before new ones are dropped
+const managerEventBuffer = 32
+
+// Events returns the lifecycle events of every controller. Events are dropped when the
+// buffer is full so a slow consumer never stalls the manager. The channel is never closed.
+func (m *Manager) Events() <-chan ManagerEvent {
+	return m.events
+}
+
+// emit sends an event without blocking
+func (m *Manager) emit(e ManagerEvent) {
+	e.Time = m.clock.Now()
+	select {
+	case m.events <- e:
+	default:
+	}
+}
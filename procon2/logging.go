@@ -0,0 +1,100 @@
+package procon2
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Logger receives the log lines of a component
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// stdLogger writes to the standard logger
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, args ...any) {
+	log.Printf(format, args...)
+}
+
+// driverLogger prefixes the lines of one controller with its player and UID, e.g. "[P2 3-14] ",
+// so interleaved logs of several controllers can be told apart
+type driverLogger struct {
+	uid   string
+	slot  atomic.Int32 // Updated when the controller changes slots
+	dedup *dedupLogger // See Dedupf
+}
+
+func newDriverLogger(uid string, slot int, dedup *dedupLogger) *driverLogger {
+	l := &driverLogger{uid: uid, dedup: dedup}
+	l.slot.Store(int32(slot))
+	return l
+}
+
+func (l *driverLogger) prefix() string {
+	return fmt.Sprintf("[P%d %s] ", l.slot.Load()+1, l.uid)
+}
+
+func (l *driverLogger) Printf(format string, args ...any) {
+	log.Print(l.prefix() + fmt.Sprintf(format, args...))
+}
+
+// Dedupf is Printf collapsing repeated lines, through the manager's dedupLogger
+func (l *driverLogger) Dedupf(format string, args ...any) {
+	l.dedup.Printf("%s", l.prefix()+fmt.Sprintf(format, args...))
+}
+
+// dedupWindow is how long identical log lines are collapsed
+const dedupWindow = 10 * time.Second
+
+// dedupEntry tracks a message suppressed within the current window
+type dedupEntry struct {
+	first      time.Time
+	suppressed int
+}
+
+// dedupLogger collapses identical log lines repeated within dedupWindow into "(repeated Nx)"
+type dedupLogger struct {
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	clock   Clock
+}
+
+func newDedupLogger(clock Clock) *dedupLogger {
+	return &dedupLogger{entries: make(map[string]*dedupEntry), clock: clock}
+}
+
+// logDedup logs like log.Printf, but repeated identical messages are only counted
+// and reported once the window elapses. Use it on paths that can fire many times per second.
+func (m *Manager) logDedup(format string, args ...any) {
+	m.faults.Printf(format, args...)
+}
+
+func (d *dedupLogger) Printf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	now := d.clock.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Report and forget every window that has elapsed
+	for m, e := range d.entries {
+		if now.Sub(e.first) < dedupWindow {
+			continue
+		}
+		if e.suppressed > 0 {
+			log.Printf("%s (repeated %dx)", m, e.suppressed)
+		}
+		delete(d.entries, m)
+	}
+
+	if e, ok := d.entries[msg]; ok {
+		e.suppressed++
+		return
+	}
+	d.entries[msg] = &dedupEntry{first: now}
+	log.Print(msg)
+}
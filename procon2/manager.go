@@ -0,0 +1,1477 @@
+// Package procon2 is an unofficial Nintendo Switch 2 Pro Controller driver.
+// It detects controllers over USB, reads their HID reports and forwards them
+// to virtual uinput gamepads.
+package procon2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/google/gousb"
+)
+
+// dryRunLogInterval throttles the states logged in dry-run mode
+const dryRunLogInterval = 250 * time.Millisecond
+
+// outputFailLimit is how many updates in a row the outputs may refuse, each after
+// retrying for up to uinputWriteTimeout, before they are recreated
+const outputFailLimit = 25
+
+// idleDeadzone is how far from center a stick must be pushed to count as input for
+// Config.IdleTimeout, so resting sticks that wobble a little don't keep a controller awake
+const idleDeadzone = 0.15
+
+const (
+	MaxPlayers    = 4
+	DRIVER_NAME   = "Nintendo Pro Controller 2"
+	PROCON_VENDOR = 0x057e
+	EVIOCGRAB     = 0x40044590
+)
+
+// ActiveDriver represents a running controller instance
+type ActiveDriver struct {
+	Driver    *Driver
+	USBDevice *gousb.Device // nil for synthetic controllers, see Manager.AddSynthetic
+	Slot      int           // 0 to 3 (Player 1-4)
+	UniqueID  string        // "Bus-Addr"
+	Serial    string        // USB serial number, empty if the device has none
+	StopChan  chan struct{}
+	WG        sync.WaitGroup
+	GrabFile  *os.File // Handle to the grabbed evdev node
+	Connected time.Time
+	Info      DeviceInfo // Zero when the controller didn't answer
+
+	Reason DisconnectReason // Why the driver stopped, set during its teardown
+
+	reslotted chan struct{}  // Signaled when Slot changes, see Manager.Assign
+	reinit    chan struct{}  // Signaled by Manager.Reinit
+	requested chan struct{}  // Signaled by Manager.Disconnect
+	stuck     *StuckDetector // nil when stuck button detection is off
+	closeOnce sync.Once
+}
+
+// close stops the driver then closes the USB device, which the ActiveDriver owns.
+// The reader stops and the interface is released first, so nothing uses the device
+// once it is closed. Calling it again does nothing.
+func (ad *ActiveDriver) close() {
+	ad.closeOnce.Do(func() {
+		ad.Driver.Close()
+		if ad.USBDevice != nil {
+			if err := ad.USBDevice.Close(); err != nil {
+				ad.Driver.log.Printf("⚠️ Could not close the USB device: %v", err)
+			}
+		}
+	})
+}
+
+// DisconnectReason tells why a driver stopped
+type DisconnectReason int
+
+const (
+	DisconnectReadTimeout DisconnectReason = iota // No report for too long
+	DisconnectRemoved                             // The device went away
+	DisconnectStalled                             // Reports kept coming but stopped changing, see Config.StallTimeout
+	DisconnectStopped                             // Stopped by the manager, e.g. on shutdown
+	DisconnectPanic                               // The driver crashed
+	DisconnectRequested                           // Given up with Manager.Disconnect
+	DisconnectIdle                                // Not touched for Config.IdleTimeout
+)
+
+func (r DisconnectReason) String() string {
+	switch r {
+	case DisconnectReadTimeout:
+		return "read-timeout"
+	case DisconnectRemoved:
+		return "usb-removed"
+	case DisconnectStalled:
+		return "stalled"
+	case DisconnectStopped:
+		return "stopped"
+	case DisconnectPanic:
+		return "panic"
+	case DisconnectRequested:
+		return "user-requested"
+	case DisconnectIdle:
+		return "idle-timeout"
+	}
+	return "unknown"
+}
+
+// DriverInfo is a copy of a running controller's state, safe to keep and read without locking
+type DriverInfo struct {
+	Slot         int
+	UniqueID     string
+	Serial       string
+	Capabilities Capabilities
+	Connected    time.Time
+	Info         DeviceInfo
+	LastReport   time.Time       // Zero until the first report
+	LastFresh    time.Time       // Last report whose content changed, a frozen controller stops updating it
+	Battery      []BatterySample // Battery history, oldest first, empty if the reports don't carry it
+	NoBattery    bool            // The report format carries no battery status the driver can read
+	Stuck        []Button        // Buttons held past Config.Stuck.Threshold
+	Rumble       bool            // The controller can rumble and rumble is on
+	Format       ReportFormat    // How the reports are parsed
+}
+
+// Manager handles detection and lifecycle of controllers
+type Manager struct {
+	ctx     *gousb.Context
+	cfg     Config
+	drivers map[string]*ActiveDriver
+	slots   [MaxPlayers]bool
+	mu      sync.Mutex
+
+	// Slots reserved for disconnected controllers, keyed by serial
+	held map[string]*heldSlot
+	// remembered is the player number of every serial seen, see Config.SlotsFile
+	remembered map[string]int
+
+	// Devices plugged in while every slot was taken, keyed by UID. Their handles stay
+	// open so they are picked up as soon as a slot frees.
+	waiting map[string]*gousb.Device
+	rescan  chan struct{}
+	// Devices given up with Disconnect or for idling, keyed by UID. Scans leave them
+	// alone until they are unplugged.
+	released map[string]bool
+
+	events  chan ManagerEvent      // See Events
+	buttons chan PlayerButtonEvent // See ButtonEvents
+
+	starting map[string]bool // UIDs whose driver is being brought up outside the lock
+	pending  sync.WaitGroup  // One per entry of starting, done once register handled it
+	closed   bool            // Set by Cleanup, drivers started afterwards stop at once
+	synthSeq atomic.Int64    // Numbers synthetic controllers, see AddSynthetic
+
+	ledMu sync.Mutex // Serializes LED updates of running controllers, see showSlots
+
+	merged  *mergedGamepad // The gamepad every controller feeds with Config.Merge, nil until the first one
+	mergeMu sync.Mutex     // Guards merged
+
+	products   atomic.Pointer[productSet] // Extra product IDs, read by scans without locking
+	productsMu sync.Mutex                 // Serializes AddProduct
+
+	clock  Clock
+	faults *dedupLogger // See logDedup
+
+	lastScan    atomic.Int64 // UnixNano of the last completed scan
+	enumerating atomic.Bool  // An OpenDevices call is in flight, possibly stuck
+}
+
+// heldSlot is a slot, and optionally its virtual gamepad, waiting for a controller to reconnect.
+// Timers check they are still registered in Manager.held before acting.
+type heldSlot struct {
+	virtual      *VirtualGamepad // nil once the reconnect grace is over
+	slot         int
+	timer        Timer // Releases the slot
+	virtualTimer Timer // Destroys the virtual gamepad
+}
+
+// NewManager creates a Manager using the given USB context
+func NewManager(ctx *gousb.Context, cfg Config) *Manager {
+	m := &Manager{
+		ctx:     ctx,
+		cfg:     cfg,
+		drivers: make(map[string]*ActiveDriver),
+
+		held:     make(map[string]*heldSlot),
+		waiting:  make(map[string]*gousb.Device),
+		released: make(map[string]bool),
+		starting: make(map[string]bool),
+		rescan:   make(chan struct{}, 1),
+		events:   make(chan ManagerEvent, managerEventBuffer),
+		buttons:  make(chan PlayerButtonEvent, buttonEventBuffer),
+		clock:    clockOrReal(cfg.Clock),
+	}
+	m.faults = newDedupLogger(m.clock)
+	m.loadProducts()
+	m.loadSlots()
+	return m
+}
+
+// IsProController reports whether a USB device descriptor matches a supported controller
+func IsProController(desc *gousb.DeviceDesc) bool {
+	// Filter by VendorID
+	if desc.Vendor != gousb.ID(PROCON_VENDOR) {
+		return false
+	}
+
+	// Accept standard Product IDs and specific clones
+	// You can add more IDs here if needed
+	if desc.Product == 0x2009 || desc.Product == 0x2019 || desc.Product == 0x2069 {
+		return true
+	}
+	return false
+}
+
+// IsNintendoGamepad matches any Nintendo device with a HID interface and an OUT endpoint to
+// talk to it, for models missing from the IsProController allowlist
+func IsNintendoGamepad(desc *gousb.DeviceDesc) bool {
+	if desc.Vendor != gousb.ID(PROCON_VENDOR) {
+		return false
+	}
+
+	hid, out := false, false
+	for _, cfg := range desc.Configs {
+		for _, iface := range cfg.Interfaces {
+			for _, alt := range iface.AltSettings {
+				if alt.Class == gousb.ClassHID {
+					hid = true
+				}
+				for _, e := range alt.Endpoints {
+					if e.Direction == gousb.EndpointDirectionOut &&
+						(e.TransferType == gousb.TransferTypeBulk || e.TransferType == gousb.TransferTypeInterrupt) {
+						out = true
+					}
+				}
+			}
+		}
+	}
+	return hid && out
+}
+
+// matcher returns the device filter selected by the config, also accepting the
+// products added with AddProduct
+func (m *Manager) matcher() func(*gousb.DeviceDesc) bool {
+	match := IsProController
+	if m.cfg.MatchClass {
+		match = IsNintendoGamepad
+	}
+	return func(desc *gousb.DeviceDesc) bool {
+		return match(desc) || m.isExtraProduct(desc)
+	}
+}
+
+// Run scans for controllers until ctx is cancelled, then stops every running driver
+func (m *Manager) Run(ctx context.Context) error {
+	m.checkStaleDevices()
+	go m.runWatchdog(ctx)
+
+	ticker := m.clock.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		m.safeScan()
+		if m.cfg.HeartbeatFile != "" {
+			if err := WriteHeartbeat(m.cfg.HeartbeatFile, m.clock.Now()); err != nil {
+				m.logDedup("⚠️ Could not write heartbeat: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("\n🛑 Shutdown signal received. Cleaning up...")
+			m.Cleanup()
+			if m.cfg.HeartbeatFile != "" {
+				os.Remove(m.cfg.HeartbeatFile)
+			}
+			return nil
+		case <-ticker.Chan():
+		case <-m.rescan:
+		}
+	}
+}
+
+// LastScan returns when Run last completed a scan, zero before the first one
+func (m *Manager) LastScan() time.Time {
+	return unixNanoTime(m.lastScan.Load())
+}
+
+// ScanHealthy reports whether a scan completed within maxAge, which stops being true
+// when the scanner is stuck, e.g. in a wedged libusb call
+func (m *Manager) ScanHealthy(maxAge time.Duration) bool {
+	last := m.LastScan()
+	return !last.IsZero() && m.clock.Now().Sub(last) <= maxAge
+}
+
+// requestScan makes Run scan right away, e.g. when a slot frees up for a waiting device
+func (m *Manager) requestScan() {
+	select {
+	case m.rescan <- struct{}{}:
+	default:
+	}
+}
+
+// checkStaleDevices warns about virtual devices left behind by a crashed run or another instance
+func (m *Manager) checkStaleDevices() {
+	stale, err := FindVirtualDevices(DRIVER_NAME)
+	if err != nil {
+		log.Printf("Note: Could not check for stale virtual devices: %v", err)
+		return
+	}
+	if len(stale) == 0 {
+		return
+	}
+	log.Printf("⚠️ Found %d virtual device(s) from a previous run or another instance:", len(stale))
+	for _, dev := range stale {
+		log.Printf("   %s", dev)
+	}
+	log.Println("   Games may see duplicate players until that process exits")
+}
+
+// safeScan runs Scan, surviving a panic so the running controllers keep going
+func (m *Manager) safeScan() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("💥 Scan crashed: %v\n%s", r, debug.Stack())
+		}
+	}()
+	m.Scan()
+}
+
+// Scan looks for new devices and starts drivers for them
+func (m *Manager) Scan() {
+	// Iterate all USB devices matching Nintendo VID, without holding the lock
+	// in case libusb blocks
+	devs, err := m.openDevices(m.cfg.ScanTimeout)
+	if errors.Is(err, errEnumerationStuck) {
+		m.logDedup("⚠️ Error scanning USB: %v", err)
+		return
+	}
+	m.lastScan.Store(m.clock.Now().UnixNano())
+
+	if err != nil {
+		m.logDedup("Error scanning USB: %v", err)
+		return
+	}
+
+	// Bring the drivers up without the lock: that takes seconds and driver teardowns need it
+	starts, moved := m.assignSlots(devs)
+	m.showSlots(moved)
+	for _, p := range starts {
+		ad, err := m.safeStart(p)
+		m.register(p, ad, err)
+	}
+}
+
+// safeStart runs startDriver, turning a panic into an error so register still clears
+// the pending start and frees its slot, and the device is retried by a later scan
+func (m *Manager) safeStart(p pendingStart) (ad *ActiveDriver, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("💥 Starting %s crashed: %v\n%s", p.uid, r, debug.Stack())
+			ad, err = nil, fmt.Errorf("driver start crashed: %v", r)
+		}
+	}()
+	return m.startDriver(p.dev, p.slot, p.uid, p.serial, p.virtual)
+}
+
+// pendingStart is a device that got a slot and still needs its driver started
+type pendingStart struct {
+	dev     *gousb.Device
+	uid     string
+	serial  string
+	slot    int
+	virtual *VirtualGamepad // Rebound from a held slot, or nil
+}
+
+// assignSlots picks a slot for each new device among devs, closing the handles it doesn't keep.
+// It also returns the running drivers moved to another slot to make room.
+func (m *Manager) assignSlots(devs []*gousb.Device) ([]pendingStart, []*ActiveDriver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var starts []pendingStart
+	var moved []*ActiveDriver
+	present := make(map[string]bool, len(devs))
+	if m.closed {
+		for _, dev := range devs {
+			dev.Close()
+		}
+		return nil, nil
+	}
+	// An explicit hidraw node can't tell which of several controllers it is
+	if m.cfg.HidrawPath != "" && len(m.drivers)+len(m.starting) == 0 && len(devs) > 1 {
+		m.logDedup("⚠️ %d controllers found but -hidraw names a single node, unplug all but one", len(devs))
+		for _, dev := range devs {
+			dev.Close()
+		}
+		devs = nil
+	}
+	for _, dev := range devs {
+		bus := dev.Desc.Bus
+		addr := dev.Desc.Address
+		uid := fmt.Sprintf("%d-%d", bus, addr)
+		present[uid] = true
+
+		// Check if we already manage this device
+		if _, exists := m.drivers[uid]; exists || m.starting[uid] {
+			dev.Close() // Already running, close this duplicate handle
+			continue
+		}
+		if m.released[uid] {
+			dev.Close()
+			continue
+		}
+		// An explicit hidraw node belongs to a single controller, see Config.HidrawPath
+		if m.cfg.HidrawPath != "" && len(m.drivers)+len(m.starting) > 0 {
+			dev.Close()
+			continue
+		}
+		// Keep using the handle of a device that was waiting for a slot
+		if w, ok := m.waiting[uid]; ok {
+			dev.Close()
+			dev = w
+			delete(m.waiting, uid)
+		}
+
+		serial, _ := dev.SerialNumber()
+
+		// Found a new device! Rebind its old virtual gamepad or find a slot.
+		var virtual *VirtualGamepad
+		slot := -1
+		if h, ok := m.held[serial]; ok && serial != "" {
+			h.timer.Stop()
+			if h.virtualTimer != nil {
+				h.virtualTimer.Stop()
+			}
+			delete(m.held, serial)
+			slot, virtual = h.slot, h.virtual
+			log.Printf("♻️ Controller %s reconnected at %s -> Back to Player %d", serial, uid, slot+1)
+		} else {
+			slot = m.assignedSlot(serial, &moved)
+			if slot == -1 {
+				slot = m.rememberedSlot(serial, &moved)
+			}
+			if slot == -1 {
+				slot = m.findUnclaimedSlot(serial)
+			}
+			if slot == -1 {
+				if _, queued := m.waiting[uid]; !queued {
+					log.Printf("⏸️ Found device at %s but all %d player slots are full, it will join when one frees up", uid, MaxPlayers)
+				}
+				m.waiting[uid] = dev
+				continue
+			}
+			log.Printf("✨ New Controller found: %s -> Assigning Player %d", uid, slot+1)
+		}
+
+		m.starting[uid] = true
+		m.pending.Add(1)
+		starts = append(starts, pendingStart{dev, uid, serial, slot, virtual})
+	}
+
+	// Released devices are taken again once they come back
+	for uid := range m.released {
+		if !present[uid] {
+			delete(m.released, uid)
+		}
+	}
+	// Forget waiting devices that were unplugged
+	for uid, dev := range m.waiting {
+		if !present[uid] {
+			log.Printf("Device %s was unplugged while waiting for a slot", uid)
+			dev.Close()
+			delete(m.waiting, uid)
+		}
+	}
+	return starts, moved
+}
+
+// register records the outcome of startDriver for a pending device and launches its loop
+func (m *Manager) register(p pendingStart, ad *ActiveDriver, err error) {
+	defer m.pending.Done()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.starting, p.uid)
+	switch {
+	case err != nil:
+		m.logDedup("❌ Failed to start driver for %s: %v", p.uid, err)
+		if p.virtual != nil {
+			p.virtual.Close()
+		}
+		if p.dev != nil {
+			p.dev.Close()
+		}
+		m.slots[p.slot] = false
+		m.emit(ManagerEvent{Type: ControllerError, Slot: p.slot, UniqueID: p.uid, Serial: p.serial, Err: err})
+	case m.closed:
+		// Cleanup ran meanwhile and waits for this start, tear the driver down right away
+		releaseGrab(ad.GrabFile)
+		ad.close()
+		m.slots[p.slot] = false
+	default:
+		m.drivers[p.uid] = ad
+		m.rememberSlot(ad.Serial, ad.Slot)
+		m.emit(ManagerEvent{Type: ControllerConnected, Slot: ad.Slot, UniqueID: ad.UniqueID, Serial: ad.Serial})
+		m.launch(ad)
+	}
+}
+
+// errEnumerationStuck is returned by openDevices when libusb doesn't answer in time
+var errEnumerationStuck = errors.New("USB enumeration is stuck")
+
+// openDevices runs OpenDevices, giving up after timeout (0 waits forever). A call that
+// times out keeps running in the background and closes what it opens once it returns,
+// and no new call starts until it does.
+func (m *Manager) openDevices(timeout time.Duration) ([]*gousb.Device, error) {
+	if !m.enumerating.CompareAndSwap(false, true) {
+		return nil, fmt.Errorf("%w: previous enumeration still running", errEnumerationStuck)
+	}
+
+	type result struct {
+		devs []*gousb.Device
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		devs, err := m.ctx.OpenDevices(m.matcher())
+		done <- result{devs, err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := m.clock.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.Chan()
+	}
+
+	select {
+	case r := <-done:
+		m.enumerating.Store(false)
+		return r.devs, r.err
+	case <-expired:
+		go func() {
+			r := <-done
+			for _, dev := range r.devs {
+				dev.Close()
+			}
+			m.enumerating.Store(false)
+			log.Println("USB enumeration recovered")
+		}()
+		return nil, fmt.Errorf("%w: no answer after %v", errEnumerationStuck, timeout)
+	}
+}
+
+// playerOf returns the player number of a running driver, whose slot can change
+func (m *Manager) playerOf(ad *ActiveDriver) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return ad.Slot + 1
+}
+
+func (m *Manager) findFreeSlot() int {
+	for i := 0; i < MaxPlayers; i++ {
+		if !m.slots[i] {
+			m.slots[i] = true
+			return i
+		}
+	}
+	return -1
+}
+
+// startDriver brings up a controller, reusing virtual when it is not nil
+func (m *Manager) startDriver(dev *gousb.Device, slotIndex int, uid, serial string, virtual *VirtualGamepad) (*ActiveDriver, error) {
+	// 1. Initialize Controller (USB)
+	iface := m.cfg.USBInterface
+	if iface.Config == 0 {
+		iface = USBInterfaceFor(dev.Desc)
+	}
+	lg := newDriverLogger(uid, slotIndex, m.faults)
+	if m.cfg.HidrawPath != "" {
+		if err := CheckHidrawNode(m.cfg.HidrawPath, uint16(dev.Desc.Vendor), uint16(dev.Desc.Product), serial); err != nil {
+			return nil, fmt.Errorf("hidraw node does not match the controller: %w", err)
+		}
+	}
+	ctrl, err := NewControllerWithOptions(dev, iface.Config, iface.Interface, ControllerOptions{Logger: lg, HIDPath: m.cfg.HidrawPath, Clock: m.clock})
+	if err != nil {
+		return nil, err
+	}
+	ctrl.SetRumbleEnabled(!m.cfg.NoRumble)
+	ctrl.SetUSBTrace(m.cfg.TraceUSB)
+
+	// 2. Exclusive Grab of original evdev node to hide it
+	grabFile, err := grabEvdev(dev, m.cfg.EvdevPath, ctrl.Profile().Grab, lg)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	// A start that fails or panics from here gives back what it set up, the node
+	// included or the controller stays hidden
+	var reader *HIDReader
+	var d *Driver
+	started := false
+	defer func() {
+		if started {
+			return
+		}
+		switch {
+		case d != nil:
+			d.Close()
+		case reader != nil:
+			reader.Close()
+			ctrl.Close()
+		default:
+			ctrl.Close()
+		}
+		releaseGrab(grabFile)
+	}()
+
+	// Without an OUT endpoint the controller can't be configured, forward whatever it sends
+	readOnly := ctrl.ReadOnly()
+	if readOnly {
+		lg.Printf("📖 Read-only: skipping init, LEDs and device info")
+	}
+
+	// 3. Send Init Sequence
+	if !readOnly {
+		if err := ctrl.SendInitSequenceWithOptions(m.initOptions()); err != nil {
+			return nil, fmt.Errorf("init failed: %w", err)
+		}
+	}
+
+	// 4. Setup HID Reader, once the controller proved it entered full-report mode
+	if ctrl.GetHIDPath() == "" {
+		return nil, fmt.Errorf("no HID path found")
+	}
+	if !readOnly {
+		if err := ctrl.WaitForFullReport(m.cfg.ReadyTimeout); err != nil {
+			return nil, fmt.Errorf("init handshake failed: %w", err)
+		}
+	}
+	reader, err = NewHIDReaderWithOptions(ctrl.GetHIDPath(), m.calibrationFor(serial), HIDReaderOptions{
+		StartupDrain: m.cfg.StartupDrain,
+		Profile:      ctrl.Profile(),
+		Clock:        m.clock,
+		StickClamp:   m.cfg.StickClamp,
+		NoRumble:     m.cfg.NoRumble,
+	})
+	if err != nil {
+		return nil, err
+	}
+	reader.SetDriftCompensation(m.cfg.Drift)
+
+	// 5. Set LEDs (Player Number)
+	// We wait a moment after init, then make sure the controller streams full reports
+	var info DeviceInfo
+	if !readOnly {
+		m.clock.Sleep(m.cfg.InitDelay)
+		if err := reader.WaitReady(m.cfg.ReadyTimeout); err != nil {
+			lg.Printf("⚠️ Not responding yet: %v", err)
+		}
+		leds := PlayerLEDPattern(m.cfg.PlayerLEDs, slotIndex+1)
+		if err := ctrl.SetLEDPattern(leds); err != nil {
+			lg.Printf("Setting LEDs failed (%v), retrying once", err)
+			if err := ctrl.SetLEDPattern(leds); err != nil {
+				lg.Printf("⚠️ Could not set LEDs: %v", err)
+			}
+		}
+
+		if info, err = ctrl.GetDeviceInfo(); err != nil {
+			lg.Printf("Note: Could not read device info: %v", err)
+		} else {
+			lg.Printf("ℹ️ This is a %s, firmware %s", info.TypeName(), info.Firmware)
+		}
+	}
+	// 6. Setup the outputs (uinput), the held virtual gamepad stands in for a new one
+	d = &Driver{controller: ctrl, reader: reader, log: lg, serial: serial}
+	if m.cfg.DryRun {
+		lg.Printf("🧪 Dry run: input is only logged")
+	} else if err := m.createOutputs(d, slotIndex, virtual, !readOnly); err != nil {
+		return nil, err
+	}
+	// A held gamepad that is no longer configured is not needed anymore
+	if virtual != nil && d.virtual != virtual {
+		virtual.Close()
+	}
+
+	ad := &ActiveDriver{
+		Driver:    d,
+		USBDevice: dev,
+		Slot:      slotIndex,
+		UniqueID:  uid,
+		Serial:    serial,
+		StopChan:  make(chan struct{}),
+		GrabFile:  grabFile,
+		reslotted: make(chan struct{}, 1),
+		reinit:    make(chan struct{}, 1),
+		requested: make(chan struct{}, 1),
+		Connected: m.clock.Now(),
+		Info:      info,
+	}
+	if m.cfg.Stuck.Threshold > 0 {
+		ad.stuck = NewStuckDetector("Controller "+uid, m.cfg.Stuck)
+	}
+	lg.Printf("✅ %s", connectSummary(ad, dev.Desc))
+
+	started = true
+	return ad, nil
+}
+
+// grabEvdev grabs the kernel evdev node of a controller as policy says, evdevPath or the
+// one found in sysfs when empty. It returns nil when nothing was grabbed, and an error
+// only for GrabAlways.
+func grabEvdev(dev *gousb.Device, evdevPath string, policy GrabPolicy, lg *driverLogger) (*os.File, error) {
+	if policy == GrabNever {
+		return nil, nil
+	}
+	grab := func() (*os.File, error) {
+		if evdevPath == "" {
+			var err error
+			if evdevPath, err = GetEvdevForUSB(int(dev.Desc.Bus), int(dev.Desc.Address)); err != nil {
+				return nil, fmt.Errorf("could not find evdev to grab: %w", err)
+			}
+		}
+		f, err := os.OpenFile(evdevPath, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, fmt.Errorf("open evdev: %w", err)
+		}
+		if err := ioctl(f.Fd(), EVIOCGRAB, 1); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("grab %s: %w", evdevPath, err)
+		}
+		lg.Printf("🔒 Grabbed original evdev: %s", evdevPath)
+		return f, nil
+	}
+
+	f, err := grab()
+	if err != nil {
+		if policy == GrabAlways {
+			return nil, err
+		}
+		lg.Dedupf("Note: %v", err)
+	}
+	return f, nil
+}
+
+// connectSummary describes a freshly started controller on one line, for support requests
+func connectSummary(ad *ActiveDriver, desc *gousb.DeviceDesc) string {
+	ctrl, reader := ad.Driver.controller, ad.Driver.reader
+
+	model := fmt.Sprintf("%s %s:%s", ctrl.Profile(), desc.Vendor, desc.Product)
+	if ad.Info != (DeviceInfo{}) {
+		model += fmt.Sprintf(" %s fw%s", ad.Info.TypeName(), ad.Info.Firmware)
+	}
+	battery := "unknown"
+	if f := reader.ReportFormat(); f.ID >= 0 && f.Battery < 0 {
+		battery = "unsupported"
+	} else if h := reader.BatteryHistory(); len(h) > 0 {
+		last := h[len(h)-1]
+		battery = fmt.Sprintf("%d/8", last.Level)
+		if last.Charging {
+			battery += "+charging"
+		}
+	}
+	return fmt.Sprintf("Connected: player=%d uid=%s serial=%q model=%q battery=%s hidraw=%s grabbed=%v report=%q",
+		ad.Slot+1, ad.UniqueID, ad.Serial, model, battery, ctrl.GetHIDPath(), ad.GrabFile != nil, reader.ReportFormat())
+}
+
+// launch starts the loop of a driver returned by startDriver, once it is registered
+func (m *Manager) launch(ad *ActiveDriver) {
+	ad.WG.Add(1)
+	go func() {
+		defer ad.WG.Done()
+		m.driverLoop(ad)
+	}()
+}
+
+// initOptions returns how the config says to send init sequences
+func (m *Manager) initOptions() InitOptions {
+	return InitOptions{
+		MaxFailRatio: m.cfg.InitFailRatio,
+		PacketDelay:  m.cfg.InitPacketDelay,
+		Verify:       m.cfg.InitVerify,
+	}
+}
+
+// calibrationFor returns the saved calibration of a controller, or Config.Calibration,
+// or DefaultCalibration
+func (m *Manager) calibrationFor(serial string) JoystickCalibration {
+	fallback := DefaultCalibration
+	if m.cfg.Calibration != nil {
+		fallback = *m.cfg.Calibration
+	}
+	if m.cfg.CalibrationDir == "" || serial == "" {
+		return fallback
+	}
+	path := CalibrationPath(m.cfg.CalibrationDir, serial)
+	cal, err := LoadCalibration(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Ignoring calibration %s: %v", path, err)
+		}
+		return fallback
+	}
+	log.Printf("📐 Using calibration %s", path)
+	return cal
+}
+
+func (m *Manager) driverLoop(ad *ActiveDriver) {
+	lg := ad.Driver.log
+	lg.Printf("🎮 Connected and running")
+
+	caps := ad.Driver.controller.Capabilities()
+	lg.Printf("🧩 Capabilities: %s", caps)
+
+	var turbo *Turbo
+	if len(m.cfg.Turbo) > 0 {
+		turbo = NewTurbo(m.cfg.Turbo)
+	}
+	var flick *Flick
+	if len(m.cfg.Flick) > 0 {
+		flick = NewFlick(m.cfg.Flick, m.cfg.FlickOptions)
+	}
+	var tracker *ButtonTracker
+	if m.cfg.ButtonEvents {
+		tracker = NewButtonTracker(m.cfg.LongPress)
+	}
+
+	reason := DisconnectReadTimeout
+	var lastLogged ControllerState
+	var lastLogTime time.Time
+	lastActive := m.clock.Now() // Last report with input, see Config.IdleTimeout
+
+	defer func() {
+		ad.Reason = reason
+		lg.Printf("🔌 Disconnected: %s", reason)
+
+		// Keep the slot, and the virtual gamepad during the grace, if the controller may come back
+		released := reason == DisconnectRequested || reason == DisconnectIdle
+		hold := reason != DisconnectStopped && !released && ad.Serial != "" && (m.cfg.ReconnectGrace > 0 || m.cfg.SlotHold > 0)
+		var virtual *VirtualGamepad
+		if hold && m.cfg.ReconnectGrace > 0 {
+			virtual = ad.Driver.detachVirtual()
+			if virtual != nil {
+				virtual.Reset()
+			}
+		}
+
+		// Cleanup resources
+		releaseGrab(ad.GrabFile)
+		ad.close()
+
+		m.mu.Lock()
+		delete(m.drivers, ad.UniqueID)
+		if released {
+			m.released[ad.UniqueID] = true
+		}
+		if hold && m.closed {
+			// Exited on its own while shutting down, Cleanup won't see a held slot anymore
+			hold = false
+			if virtual != nil {
+				virtual.Close()
+			}
+		}
+		if hold {
+			m.holdSlot(ad.Serial, ad.Slot, virtual)
+		} else {
+			m.slots[ad.Slot] = false
+			if len(m.waiting) > 0 {
+				m.requestScan()
+			}
+		}
+		m.emit(ManagerEvent{Type: ControllerDisconnected, Slot: ad.Slot, UniqueID: ad.UniqueID, Serial: ad.Serial, Reason: reason})
+		m.mu.Unlock()
+	}()
+
+	// Registered after the teardown so it runs first, letting the teardown close every fd
+	defer func() {
+		if r := recover(); r != nil {
+			reason = DisconnectPanic
+			lg.Printf("💥 Driver crashed, other players keep running: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	ticker := m.clock.NewTicker(2 * time.Millisecond)
+	defer ticker.Stop()
+
+	failCount := 0
+	outputFails := 0       // Updates in a row an output refused
+	var reinitAt time.Time // Last re-init, zero when none ran
+
+	for {
+		select {
+		case <-ad.StopChan:
+			reason = DisconnectStopped
+			return
+		case <-ad.requested:
+			reason = DisconnectRequested
+			return
+		case <-ad.reslotted:
+			m.renameOutputs(ad)
+		case <-ad.reinit:
+			reinitAt = m.clock.Now()
+			m.reinit(ad)
+		case <-ticker.Chan():
+			if m.cfg.StallTimeout > 0 && ad.Driver.reader.Stalled(m.cfg.StallTimeout) {
+				fresh := ad.Driver.reader.LastFreshReport()
+				switch {
+				case m.cfg.StallReinit && !ad.Driver.controller.ReadOnly() && fresh.After(reinitAt):
+					// Once per stall: a fresh report must have come since the last attempt
+					lg.Printf("🧊 Stalled: no fresh report since %s, re-running init", fresh.Format("15:04:05.000"))
+					reinitAt = m.clock.Now()
+					m.reinit(ad)
+					continue
+				case m.clock.Now().Sub(reinitAt) > m.cfg.StallTimeout:
+					lg.Printf("🧊 Stalled: no fresh report since %s", fresh.Format("15:04:05.000"))
+					reason = DisconnectStalled
+					return
+				}
+			}
+			state, err := ad.Driver.reader.ReadStateTimeout(100 * time.Millisecond)
+			if err != nil {
+				if errors.Is(err, syscall.ENODEV) || errors.Is(err, syscall.EIO) {
+					// hidraw fails reads this way once the device is unplugged
+					reason = DisconnectRemoved
+					return
+				}
+				if errors.Is(err, ErrShortReport) {
+					// Counted like a missed report, the state it carries is unusable
+					lg.Dedupf("⚠️ Dropped a truncated report: %v", err)
+				}
+				failCount++
+				if failCount == 3 { // ~300ms without reports
+					// Release held inputs during the hiccup, normal forwarding resumes with the next report
+					ad.Driver.Update(ControllerState{})
+				}
+				if failCount > 20 { // ~2 seconds of failure
+					lg.Dedupf("Read timeout/error: %v", err)
+					return // Exit loop, triggers cleanup
+				}
+				continue
+			}
+			failCount = 0
+			if now := m.clock.Now(); state.HasInput(idleDeadzone) {
+				lastActive = now
+			} else if m.cfg.IdleTimeout > 0 && now.Sub(lastActive) >= m.cfg.IdleTimeout {
+				lg.Printf("💤 No input for %v", m.cfg.IdleTimeout)
+				reason = DisconnectIdle
+				return
+			}
+			if !caps.Paddles {
+				state.PaddleLeft, state.PaddleRight = false, false
+			}
+			if ad.stuck != nil {
+				state = ad.stuck.Apply(state, m.clock.Now())
+			}
+			if tracker != nil {
+				// Before turbo and flick, so hold durations are the player's own
+				for _, e := range tracker.Feed(state, m.clock.Now()) {
+					m.emitButton(PlayerButtonEvent{ButtonEvent: e, Slot: m.playerOf(ad) - 1, UniqueID: ad.UniqueID})
+				}
+			}
+			if turbo != nil {
+				state = turbo.Apply(state, m.clock.Now())
+			}
+			if flick != nil {
+				state = flick.Apply(state, m.clock.Now())
+			}
+			if m.cfg.DryRun {
+				if now := m.clock.Now(); now.Sub(lastLogTime) >= dryRunLogInterval && !state.SameInput(lastLogged) {
+					lg.Printf("🧪 %s", formatStateLine(state))
+					lastLogged, lastLogTime = state, now
+				}
+				continue
+			}
+			if err := ad.Driver.Update(state); err != nil {
+				outputFails++
+				lg.Dedupf("⚠️ Input not forwarded: %v", err)
+				if outputFails == outputFailLimit {
+					// Recreated devices start from a clean queue, games see them replug
+					lg.Printf("🔧 The virtual devices refused %d updates in a row, recreating them", outputFails)
+					m.recreateOutputs(ad)
+					outputFails = 0
+				}
+				continue
+			}
+			outputFails = 0
+		}
+	}
+}
+
+// Snapshot returns information about every running controller, ordered by slot
+func (m *Manager) Snapshot() []DriverInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]DriverInfo, 0, len(m.drivers))
+	for _, ad := range m.drivers {
+		info := DriverInfo{
+			Slot:         ad.Slot,
+			UniqueID:     ad.UniqueID,
+			Serial:       ad.Serial,
+			Capabilities: ad.Driver.controller.Capabilities(),
+			Connected:    ad.Connected,
+			Info:         ad.Info,
+			LastReport:   ad.Driver.reader.LastReport(),
+			LastFresh:    ad.Driver.reader.LastFreshReport(),
+			Battery:      ad.Driver.reader.BatteryHistory(),
+			Rumble:       ad.Driver.controller.RumbleEnabled(),
+			Format:       ad.Driver.reader.ReportFormat(),
+		}
+		info.NoBattery = info.Format.ID >= 0 && info.Format.Battery < 0
+		if ad.stuck != nil {
+			info.Stuck = ad.stuck.Stuck()
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Slot < infos[j].Slot })
+	return infos
+}
+
+// SlotOccupied reports whether a running controller uses slot i (0 to MaxPlayers-1), i.e.
+// is Player i+1. Slots held for a reconnecting controller or taken by one still starting
+// don't count, since nobody can play with them yet.
+func (m *Manager) SlotOccupied(i int) bool {
+	if i < 0 || i >= MaxPlayers {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.driverAt(i) != nil
+}
+
+// ConnectedCount returns how many controllers are running
+func (m *Manager) ConnectedCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.drivers)
+}
+
+// SetTrim changes the per-axis center trim of a running controller, in raw units, see
+// JoystickCalibration. With Config.CalibrationDir set, the calibration file of the
+// controller is rewritten so the trim survives a reconnect.
+func (m *Manager) SetTrim(uid string, lx, ly, rx, ry int) error {
+	m.mu.Lock()
+	ad, ok := m.drivers[uid]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no running controller at %s", uid)
+	}
+
+	reader := ad.Driver.reader
+	reader.SetTrim(lx, ly, rx, ry)
+	ad.Driver.log.Printf("🎯 Stick trim set to L(%+d, %+d) R(%+d, %+d)", lx, ly, rx, ry)
+	if m.cfg.CalibrationDir == "" || ad.Serial == "" {
+		return nil
+	}
+	path := CalibrationPath(m.cfg.CalibrationDir, ad.Serial)
+	if err := SaveCalibration(path, reader.Calibration()); err != nil {
+		return fmt.Errorf("trim applied but not saved: %w", err)
+	}
+	return nil
+}
+
+// SetDrift changes the anti-drift recentering of a running controller, see DriftOptions.
+// A zero Rate turns it off and drops the correction built up so far.
+func (m *Manager) SetDrift(uid string, opts DriftOptions) error {
+	m.mu.Lock()
+	ad, ok := m.drivers[uid]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no running controller at %s", uid)
+	}
+
+	ad.Driver.reader.SetDriftCompensation(opts)
+	if opts.Rate == 0 {
+		ad.Driver.log.Printf("🧭 Anti-drift off")
+	} else {
+		ad.Driver.log.Printf("🧭 Anti-drift on, up to %d raw units", opts.MaxCorrection)
+	}
+	return nil
+}
+
+// UnmaskButton forwards a stuck button of a running controller again, until it is next released
+func (m *Manager) UnmaskButton(uid string, b Button) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ad, ok := m.drivers[uid]
+	if !ok {
+		return fmt.Errorf("no running controller at %s", uid)
+	}
+	if ad.stuck == nil {
+		return fmt.Errorf("stuck button detection is off")
+	}
+	ad.stuck.Unmask(b)
+	return nil
+}
+
+// SetRumble turns rumble on or off for a running controller, see Controller.SwitchRumble
+func (m *Manager) SetRumble(uid string, on bool) error {
+	m.mu.Lock()
+	ad, ok := m.drivers[uid]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no running controller at %s", uid)
+	}
+
+	ad.Driver.reader.SetRumbleEnabled(on)
+	if err := ad.Driver.controller.SwitchRumble(on); err != nil {
+		return fmt.Errorf("switch rumble: %w", err)
+	}
+	if on {
+		ad.Driver.log.Printf("📳 Rumble turned on")
+	} else {
+		ad.Driver.log.Printf("📴 Rumble turned off")
+	}
+	return nil
+}
+
+// Reinit re-runs the init sequence of a running controller, e.g. when its input froze,
+// keeping its virtual devices. It runs in the background, the result is logged.
+func (m *Manager) Reinit(uid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ad, ok := m.drivers[uid]
+	if !ok {
+		return fmt.Errorf("no running controller at %s", uid)
+	}
+	if ad.Driver.controller.ReadOnly() {
+		return fmt.Errorf("controller at %s is read-only", uid)
+	}
+	select {
+	case ad.reinit <- struct{}{}:
+	default: // Already pending
+	}
+	return nil
+}
+
+// Disconnect stops a running controller and frees its player slot. Scans leave the
+// controller alone until it is plugged in again.
+func (m *Manager) Disconnect(uid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ad, ok := m.drivers[uid]
+	if !ok {
+		return fmt.Errorf("no running controller at %s", uid)
+	}
+	select {
+	case ad.requested <- struct{}{}:
+	default: // Already pending
+	}
+	return nil
+}
+
+// reinit re-sends the init sequences of a driver then restores its LEDs and motion sensors
+func (m *Manager) reinit(ad *ActiveDriver) {
+	lg := ad.Driver.log
+	if err := ad.Driver.reinit(m.initOptions()); err != nil {
+		lg.Printf("⚠️ Re-init failed: %v", err)
+		return
+	}
+
+	ctrl := ad.Driver.controller
+	m.ledMu.Lock()
+	err := ctrl.SetLEDPattern(PlayerLEDPattern(m.cfg.PlayerLEDs, m.playerOf(ad)))
+	m.ledMu.Unlock()
+	if err != nil {
+		lg.Printf("⚠️ Could not set LEDs: %v", err)
+	}
+	for _, out := range ad.Driver.outputs {
+		if _, ok := out.(*VirtualMotion); ok {
+			if err := ctrl.EnableIMU(); err != nil {
+				lg.Printf("⚠️ Could not enable the motion sensors: %v", err)
+			}
+		}
+	}
+	lg.Printf("🔁 Re-initialized")
+}
+
+// Cleanup stops every running driver and waits for them to exit, along with the
+// drivers still starting. The lock is released while waiting since the exiting
+// drivers need it to unregister.
+func (m *Manager) Cleanup() {
+	m.mu.Lock()
+	m.closed = true
+	drivers := make([]*ActiveDriver, 0, len(m.drivers))
+	for _, ad := range m.drivers {
+		drivers = append(drivers, ad)
+		close(ad.StopChan)
+	}
+	m.mu.Unlock()
+
+	m.pending.Wait()
+	for _, ad := range drivers {
+		ad.WG.Wait()
+	}
+
+	m.mu.Lock()
+	for serial, h := range m.held {
+		if h.virtual != nil {
+			h.virtual.Close()
+		}
+		m.slots[h.slot] = false
+		delete(m.held, serial)
+	}
+	for uid, dev := range m.waiting {
+		dev.Close()
+		delete(m.waiting, uid)
+	}
+	m.mu.Unlock()
+}
+
+// holdSlot reserves a disconnected controller's slot for its serial, keeping its
+// virtual gamepad alive for the reconnect grace period when virtual is not nil.
+// Must be called with m.mu held.
+func (m *Manager) holdSlot(serial string, slot int, virtual *VirtualGamepad) {
+	h := &heldSlot{virtual: virtual, slot: slot}
+
+	if virtual != nil {
+		log.Printf("⏳ Keeping Player %d virtual device for %v in case it reconnects", slot+1, m.cfg.ReconnectGrace)
+		h.virtualTimer = m.clock.AfterFunc(m.cfg.ReconnectGrace, func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			// Already rebound or cleaned up
+			if m.held[serial] != h || h.virtual == nil {
+				return
+			}
+			h.virtual.Close()
+			h.virtual = nil
+			log.Printf("🔌 Player %d did not reconnect, virtual device removed", slot+1)
+		})
+	}
+
+	hold := max(m.cfg.ReconnectGrace, m.cfg.SlotHold)
+	if m.cfg.SlotHold > 0 {
+		log.Printf("⏳ Holding Player %d slot for %s during %v", slot+1, serial, hold)
+	}
+	h.timer = m.clock.AfterFunc(hold, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if m.held[serial] != h {
+			return
+		}
+		delete(m.held, serial)
+		if h.virtual != nil {
+			h.virtual.Close()
+			log.Printf("🔌 Player %d did not reconnect, virtual device removed", slot+1)
+		}
+		m.slots[slot] = false
+		if len(m.waiting) > 0 {
+			m.requestScan()
+		}
+	})
+	m.held[serial] = h
+}
+
+// createOutputs adds the configured outputs of the player in slotIndex to d, using
+// virtual as its gamepad when it is not nil. enableIMU turns the motion sensors on
+// for the motion output.
+func (m *Manager) createOutputs(d *Driver, slotIndex int, virtual *VirtualGamepad, enableIMU bool) error {
+	ctrl := d.controller
+	for _, kind := range m.outputsFor(d.serial) {
+		var out Output
+		var err error
+		switch kind {
+		case OutputGamepad:
+			if m.cfg.Merge {
+				out, err = m.mergedOutput(slotIndex)
+				break
+			}
+			if virtual == nil {
+				opts := m.cfg.Gamepad
+				if opts.Bindings == nil {
+					opts.Bindings = m.cfg.Bindings
+				}
+				if opts.Identity == (VirtualIdentity{}) {
+					opts.Identity = m.identityFor(d.serial, ctrl.profile)
+				}
+				if opts.Identity != IdentityProController {
+					d.log.Printf("🎭 Presenting as %s (%04x:%04x), SDL_GAMECONTROLLERCONFIG=%q",
+						opts.Identity.Name, opts.Identity.Vendor, opts.Identity.Product, opts.SDLMapping())
+				}
+				virtual, err = NewVirtualGamepad(slotIndex+1, opts)
+				if err != nil {
+					break
+				}
+				virtual.SetSOCDMode(m.cfg.SOCD)
+			}
+			d.virtual, out = virtual, virtual
+		case OutputKeyboard:
+			out, err = NewVirtualKeyboardWithBindings(slotIndex+1, m.cfg.Bindings)
+		case OutputMouse:
+			out, err = NewVirtualMouse(slotIndex+1, m.cfg.Mouse)
+		case OutputMotion:
+			if !ctrl.Capabilities().IMU {
+				d.log.Printf("Note: No motion sensors, skipping the motion device")
+				continue
+			}
+			if f := d.reader.ReportFormat(); f.IMU < 0 {
+				// A device that never moves would only mislead games
+				if f.ID < 0 {
+					d.log.Printf("⚠️ No full report yet to tell where its motion samples are, skipping the motion device")
+				} else {
+					d.log.Printf("⚠️ Reports 0x%02x carry no motion samples the driver can read, skipping the motion device", f.ID)
+				}
+				continue
+			}
+			if enableIMU {
+				if err := ctrl.EnableIMU(); err != nil {
+					d.log.Printf("⚠️ Could not enable the motion sensors: %v", err)
+				}
+			}
+			out, err = NewVirtualMotion(slotIndex + 1)
+		default:
+			err = fmt.Errorf("unknown output %v", kind)
+		}
+		if err != nil {
+			return fmt.Errorf("%v output: %w", kind, err)
+		}
+		if c, ok := out.(clockSetter); ok {
+			c.setClock(m.clock)
+		}
+		d.outputs = append(d.outputs, out)
+	}
+	return nil
+}
+
+// outputsFor returns the backends of the controller with this serial, see Config.SerialOutputs
+func (m *Manager) outputsFor(serial string) []OutputKind {
+	if kinds, ok := m.cfg.SerialOutputs[serial]; ok && serial != "" {
+		return kinds
+	}
+	return m.cfg.Outputs
+}
+
+// renameOutputs recreates the outputs of a driver that moved to another slot, since
+// their names carry the player number. Games would otherwise show the old player.
+func (m *Manager) renameOutputs(ad *ActiveDriver) {
+	if len(ad.Driver.outputs) == 0 {
+		return
+	}
+	ad.Driver.log.Printf("🏷️ Recreating the virtual devices as Player %d", m.playerOf(ad))
+	m.recreateOutputs(ad)
+}
+
+// recreateOutputs replaces the outputs of a driver with new ones for its current player
+func (m *Manager) recreateOutputs(ad *ActiveDriver) {
+	player := m.playerOf(ad)
+	// Closing sends a neutral frame first, so nothing stays pressed on the old devices
+	ad.Driver.closeOutputs()
+	if err := m.createOutputs(ad.Driver, player-1, nil, false); err != nil {
+		ad.Driver.log.Printf("⚠️ %v", err)
+	}
+}
+
+// notifyReslot tells a running driver its slot changed. Must be called with m.mu held.
+func (m *Manager) notifyReslot(ad *ActiveDriver) {
+	select {
+	case ad.reslotted <- struct{}{}:
+	default:
+	}
+}
+
+// releaseGrab ungrabs and closes an evdev node grabbed in startDriver, f may be nil
+func releaseGrab(f *os.File) {
+	if f == nil {
+		return
+	}
+	ioctl(f.Fd(), EVIOCGRAB, 0)
+	f.Close()
+}
+
+// Driver struct wrapper
+type Driver struct {
+	controller *Controller
+	reader     *HIDReader
+	virtual    *VirtualGamepad // Also in outputs, nil when no gamepad output is configured
+	outputs    []Output
+	log        *driverLogger
+	closeOnce  sync.Once
+	source     io.Closer // Write end of a synthetic controller's reports, nil for real ones
+	serial     string
+}
+
+// Controller returns the USB controller handle
+func (d *Driver) Controller() *Controller {
+	return d.controller
+}
+
+// Reader returns the HID input reader
+func (d *Driver) Reader() *HIDReader {
+	return d.reader
+}
+
+// Virtual returns the virtual gamepad the input is forwarded to, or nil
+func (d *Driver) Virtual() *VirtualGamepad {
+	return d.virtual
+}
+
+// Outputs returns every backend the input is forwarded to
+func (d *Driver) Outputs() []Output {
+	return d.outputs
+}
+
+// Update forwards a state to every output, returning the first error
+func (d *Driver) Update(state ControllerState) error {
+	var firstErr error
+	for _, out := range d.outputs {
+		if err := out.Update(state); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// detachVirtual removes the virtual gamepad from the outputs so Close leaves it alive
+func (d *Driver) detachVirtual() *VirtualGamepad {
+	virtual := d.virtual
+	if virtual == nil {
+		return nil
+	}
+	for i, out := range d.outputs {
+		if out == Output(virtual) {
+			d.outputs = append(d.outputs[:i], d.outputs[i+1:]...)
+			break
+		}
+	}
+	d.virtual = nil
+	return virtual
+}
+
+// closeOutputs closes and forgets every output
+func (d *Driver) closeOutputs() {
+	for _, out := range d.outputs {
+		out.Close()
+	}
+	d.outputs = nil
+	d.virtual = nil
+}
+
+// Reinit re-sends the controller init sequence then the hidraw init commands,
+// leaving the outputs untouched
+func (d *Driver) Reinit(maxFailRatio float64) error {
+	return d.reinit(InitOptions{MaxFailRatio: maxFailRatio})
+}
+
+func (d *Driver) reinit(opts InitOptions) error {
+	if d.controller.ReadOnly() {
+		return fmt.Errorf("controller is read-only")
+	}
+	if err := d.controller.SendInitSequenceWithOptions(opts); err != nil {
+		return err
+	}
+	return d.reader.Reinit()
+}
+
+// Close closes the outputs, stops the reader then releases the interface, in that order.
+// The USB device is left open. Calling Close again does nothing.
+func (d *Driver) Close() {
+	d.closeOnce.Do(d.close)
+}
+
+func (d *Driver) close() {
+	d.closeOutputs()
+	if d.reader != nil {
+		d.reader.Close()
+	}
+	if d.controller != nil {
+		d.controller.Close()
+	}
+}
@@ -0,0 +1,329 @@
+package procon2
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when Advance is called
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending After, Sleep, timer or ticker of a fakeClock
+type fakeWaiter struct {
+	at      time.Time
+	every   time.Duration // Non zero for tickers
+	ch      chan time.Time
+	fn      func()
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) add(w *fakeWaiter) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.add(&fakeWaiter{at: c.Now().Add(d), ch: make(chan time.Time, 1)}).ch
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return &fakeTimer{c, c.add(&fakeWaiter{at: c.Now().Add(d), fn: f})}
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	return &fakeTimer{c, c.add(&fakeWaiter{at: c.Now().Add(d), ch: make(chan time.Time, 1)})}
+}
+
+// Sleep blocks until Advance moves the clock past d from now
+func (c *fakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	return &fakeTicker{fakeTimer{c, c.add(&fakeWaiter{at: c.Now().Add(d), every: d, ch: make(chan time.Time, 1)})}}
+}
+
+// Advance moves the clock forward, firing what falls due on the way
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var due []func()
+	kept := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.stopped {
+			continue
+		}
+		if w.at.After(now) {
+			kept = append(kept, w)
+			continue
+		}
+		if w.fn != nil {
+			due = append(due, w.fn)
+			continue
+		}
+		select {
+		case w.ch <- now:
+		default: // Like time.Ticker, a slow reader misses ticks
+		}
+		if w.every > 0 {
+			for !w.at.After(now) {
+				w.at = w.at.Add(w.every)
+			}
+			kept = append(kept, w)
+		}
+	}
+	c.waiters = kept
+	c.mu.Unlock()
+
+	for _, f := range due {
+		f()
+	}
+}
+
+// fakeTimer is the Timer of a fakeClock
+type fakeTimer struct {
+	c *fakeClock
+	w *fakeWaiter
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	was := !t.w.stopped
+	t.w.stopped = true
+	return was
+}
+
+// fakeTicker is the Ticker of a fakeClock
+type fakeTicker struct {
+	fakeTimer
+}
+
+func (t *fakeTimer) Chan() <-chan time.Time { return t.w.ch }
+
+func (t *fakeTicker) Stop() { t.fakeTimer.Stop() }
+
+func TestCleanupReturnsPromptly(t *testing.T) {
+	clock := newFakeClock()
+	cfg := DefaultConfig
+	cfg.DryRun = true
+	cfg.Clock = clock
+	m := NewManager(nil, cfg)
+
+	uids := make([]string, MaxPlayers)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("pipe-%d", i+1)
+		go writeRestingReports(addPipeDriver(t, m, uids[i], i))
+	}
+	if got := m.ConnectedCount(); got != MaxPlayers {
+		t.Fatalf("ConnectedCount = %d, want %d", got, MaxPlayers)
+	}
+
+	// Half of them lose their device: as the clock runs, their loops read the error
+	// and tear themselves down while Cleanup stops the others
+	m.mu.Lock()
+	for _, uid := range uids[:MaxPlayers/2] {
+		select {
+		case m.drivers[uid].Driver.reader.errChan <- syscall.ENODEV:
+		default: // An error is already pending
+		}
+	}
+	m.mu.Unlock()
+	ticking := make(chan struct{})
+	defer close(ticking)
+	go func() {
+		for {
+			select {
+			case <-ticking:
+				return
+			default:
+				clock.Advance(2 * time.Millisecond)
+				time.Sleep(50 * time.Microsecond)
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		m.Cleanup()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Cleanup did not return")
+	}
+
+	if got := m.ConnectedCount(); got != 0 {
+		t.Errorf("ConnectedCount after Cleanup = %d, want 0", got)
+	}
+	m.mu.Lock()
+	if len(m.drivers) != 0 {
+		t.Errorf("%d drivers left after Cleanup", len(m.drivers))
+	}
+	for i := 0; i < MaxPlayers; i++ {
+		if m.slots[i] {
+			t.Errorf("slot %d still taken after Cleanup", i)
+		}
+	}
+	m.mu.Unlock()
+	disconnected := 0
+	for len(m.Events()) > 0 {
+		if e := <-m.Events(); e.Type == ControllerDisconnected {
+			disconnected++
+		}
+	}
+	if disconnected != MaxPlayers {
+		t.Errorf("%d disconnect events, want %d", disconnected, MaxPlayers)
+	}
+	if _, err := m.AddSynthetic("LATE"); err == nil {
+		t.Error("AddSynthetic succeeded after Cleanup")
+	}
+}
+
+// addPipeDriver starts a driver in slot reading the reports the test writes to the
+// returned pipe, the way AddSynthetic does with random ones
+func addPipeDriver(t *testing.T, m *Manager, uid string, slot int) *os.File {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+
+	lg := newDriverLogger(uid, slot, m.faults)
+	ctrl := &Controller{hidPath: "pipe", profile: ProController2Profile, logger: lg, clock: m.clock}
+	reader := newHIDReader(r, DefaultCalibration, HIDReaderOptions{Profile: ctrl.profile, Clock: m.clock})
+	go reader.runReadLoop(time.Time{})
+	ad := &ActiveDriver{
+		Driver:    &Driver{controller: ctrl, reader: reader, log: lg},
+		Slot:      slot,
+		UniqueID:  uid,
+		StopChan:  make(chan struct{}),
+		reslotted: make(chan struct{}, 1),
+		reinit:    make(chan struct{}, 1),
+		requested: make(chan struct{}, 1),
+		Connected: m.clock.Now(),
+	}
+
+	m.mu.Lock()
+	m.slots[slot] = true
+	m.starting[uid] = true
+	m.pending.Add(1)
+	m.mu.Unlock()
+	m.register(pendingStart{uid: uid, slot: slot}, ad, nil)
+	return w
+}
+
+// writeRestingReports writes full reports with nothing pressed and the sticks at rest
+// until w is closed
+func writeRestingReports(w *os.File) {
+	report := make([]byte, 64)
+	report[0] = 0x09
+	c := DefaultCalibration
+	putStick(report, 6, c.LXCenter, c.LYCenter)
+	putStick(report, 9, c.RXCenter, c.RYCenter)
+	for counter := byte(0); ; counter++ {
+		report[1] = counter
+		if _, err := w.Write(report); err != nil {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDisconnectReasons(t *testing.T) {
+	tests := []struct {
+		name string
+		idle time.Duration
+		stop func(m *Manager, uid string, w *os.File)
+		want DisconnectReason
+	}{
+		{"read timeout", 0, func(m *Manager, uid string, w *os.File) { w.Close() }, DisconnectReadTimeout},
+		{"usb removed", 0, func(m *Manager, uid string, w *os.File) {
+			m.mu.Lock()
+			m.drivers[uid].Driver.reader.errChan <- syscall.ENODEV
+			m.mu.Unlock()
+		}, DisconnectRemoved},
+		{"user requested", 0, func(m *Manager, uid string, w *os.File) {
+			if err := m.Disconnect(uid); err != nil {
+				t.Errorf("Disconnect: %v", err)
+			}
+		}, DisconnectRequested},
+		{"idle timeout", time.Second, func(m *Manager, uid string, w *os.File) {}, DisconnectIdle},
+		{"stopped", 0, func(m *Manager, uid string, w *os.File) { go m.Cleanup() }, DisconnectStopped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			cfg := DefaultConfig
+			cfg.DryRun = true
+			cfg.Clock = clock
+			cfg.IdleTimeout = tt.idle
+			m := NewManager(nil, cfg)
+
+			const uid = "pipe-1"
+			w := addPipeDriver(t, m, uid, 0)
+			if tt.want != DisconnectReadTimeout {
+				go writeRestingReports(w)
+			}
+			ticking := make(chan struct{})
+			defer close(ticking)
+			go func() {
+				for {
+					select {
+					case <-ticking:
+						return
+					default:
+						clock.Advance(2 * time.Millisecond)
+						time.Sleep(50 * time.Microsecond)
+					}
+				}
+			}()
+			tt.stop(m, uid, w)
+
+			deadline := time.After(5 * time.Second)
+			for {
+				select {
+				case e := <-m.Events():
+					if e.Type != ControllerDisconnected {
+						continue
+					}
+					if e.Reason != tt.want {
+						t.Errorf("reason %s, want %s", e.Reason, tt.want)
+					}
+					m.mu.Lock()
+					released := m.released[uid]
+					m.mu.Unlock()
+					if want := tt.want == DisconnectRequested || tt.want == DisconnectIdle; released != want {
+						t.Errorf("released = %v, want %v", released, want)
+					}
+					return
+				case <-deadline:
+					t.Fatal("no disconnect")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package procon2
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+)
+
+// StickPolicy selects how the sticks of several controllers merged into one are combined
+type StickPolicy int
+
+const (
+	StickLastMoved    StickPolicy = iota // The stick moved most recently, beyond the deadzone, wins
+	StickAverage                         // The average of the sticks out of the deadzone
+	StickMaxMagnitude                    // The stick pushed the furthest wins
+)
+
+// ParseStickPolicy converts a policy name as used on the command line
+func ParseStickPolicy(name string) (StickPolicy, error) {
+	switch name {
+	case "last-moved":
+		return StickLastMoved, nil
+	case "average":
+		return StickAverage, nil
+	case "max-magnitude":
+		return StickMaxMagnitude, nil
+	}
+	return StickLastMoved, fmt.Errorf("unknown stick policy %q (expected last-moved, average or max-magnitude)", name)
+}
+
+// stickPos is a normalized stick position
+type stickPos struct{ x, y float64 }
+
+func (p stickPos) magnitude() float64 {
+	return math.Hypot(p.x, p.y)
+}
+
+// sticks returns the left and right stick of a state
+func sticks(s ControllerState) [2]stickPos {
+	j := s.Joysticks
+	return [2]stickPos{{j.LX, j.LY}, {j.RX, j.RY}}
+}
+
+// StateMerger combines the states of several controllers into the state of a single
+// virtual gamepad: a button is held while any controller holds it, and each stick is
+// arbitrated by a StickPolicy. Update may be called from one goroutine per source.
+type StateMerger struct {
+	mu       sync.Mutex
+	policy   StickPolicy
+	deadzone float64
+	states   []ControllerState
+	anchors  [][2]stickPos // Where each source stick was last seen moving
+	moved    [][2]uint64   // When each source stick last moved, in Update calls
+	seq      uint64
+}
+
+// NewStateMerger merges sources controllers. A stick moves, for StickLastMoved, when it
+// leaves the deadzone or travels further than the deadzone from where it last moved.
+// Sticks within the deadzone are ignored by StickAverage.
+func NewStateMerger(sources int, policy StickPolicy, deadzone float64) *StateMerger {
+	return &StateMerger{
+		policy:   policy,
+		deadzone: deadzone,
+		states:   make([]ControllerState, sources),
+		anchors:  make([][2]stickPos, sources),
+		moved:    make([][2]uint64, sources),
+	}
+}
+
+// Update records the latest state of source, from 0, and returns the merged state
+func (m *StateMerger) Update(source int, state ControllerState) ControllerState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.seq++
+	m.states[source] = state
+	for i, p := range sticks(state) {
+		a := m.anchors[source][i]
+		if p.magnitude() <= m.deadzone {
+			// Back to center, leaving the deadzone again counts as moving
+			m.anchors[source][i] = stickPos{}
+			continue
+		}
+		if a.magnitude() <= m.deadzone || math.Hypot(p.x-a.x, p.y-a.y) > m.deadzone {
+			m.anchors[source][i] = p
+			m.moved[source][i] = m.seq
+		}
+	}
+
+	merged := state // Motion and raw readings come from the source just updated
+	for _, b := range AllButtons {
+		pressed := false
+		for _, s := range m.states {
+			pressed = pressed || s.Pressed(b)
+		}
+		merged.SetPressed(b, pressed)
+	}
+	left, right := m.stick(0), m.stick(1)
+	merged.Joysticks = JoystickValues{LX: left.x, LY: left.y, RX: right.x, RY: right.y}
+	return merged
+}
+
+// stick arbitrates stick i, 0 for the left one and 1 for the right one
+func (m *StateMerger) stick(i int) stickPos {
+	switch m.policy {
+	case StickAverage:
+		var sum stickPos
+		n := 0
+		for _, s := range m.states {
+			if p := sticks(s)[i]; p.magnitude() > m.deadzone {
+				sum.x, sum.y = sum.x+p.x, sum.y+p.y
+				n++
+			}
+		}
+		if n == 0 {
+			return stickPos{}
+		}
+		return stickPos{sum.x / float64(n), sum.y / float64(n)}
+	case StickMaxMagnitude:
+		var best stickPos
+		for _, s := range m.states {
+			if p := sticks(s)[i]; p.magnitude() > best.magnitude() {
+				best = p
+			}
+		}
+		return best
+	default:
+		// The most recent mover out of the deadzone, so letting go of a stick hands
+		// over to one still held. Otherwise the most recent mover, resting near center.
+		best, bestHeld := -1, -1
+		for src, s := range m.states {
+			if best == -1 || m.moved[src][i] > m.moved[best][i] {
+				best = src
+			}
+			if sticks(s)[i].magnitude() > m.deadzone && (bestHeld == -1 || m.moved[src][i] > m.moved[bestHeld][i]) {
+				bestHeld = src
+			}
+		}
+		if bestHeld != -1 {
+			best = bestHeld
+		}
+		if best == -1 {
+			return stickPos{}
+		}
+		return sticks(m.states[best])[i]
+	}
+}
+
+// mergedGamepad is the virtual gamepad every controller feeds when Config.Merge is set
+type mergedGamepad struct {
+	mu      sync.Mutex // Serializes the merge and the write of each update
+	merger  *StateMerger
+	virtual *VirtualGamepad
+	users   int // Open mergeSources, guarded by Manager.mergeMu
+}
+
+// mergeSource is the gamepad output of one merged controller, its source is its slot
+type mergeSource struct {
+	m      *Manager
+	g      *mergedGamepad
+	source int
+}
+
+// Update merges state with the other controllers and forwards the result
+func (s *mergeSource) Update(state ControllerState) error {
+	s.g.mu.Lock()
+	defer s.g.mu.Unlock()
+	return s.g.virtual.Update(s.g.merger.Update(s.source, state))
+}
+
+// Close releases what the controller held, and the gamepad once nobody feeds it
+func (s *mergeSource) Close() error {
+	s.m.mergeMu.Lock()
+	defer s.m.mergeMu.Unlock()
+
+	err := s.Update(ControllerState{})
+	s.g.users--
+	if s.g.users > 0 {
+		return err
+	}
+	if s.m.merged == s.g {
+		s.m.merged = nil
+	}
+	return s.g.virtual.Close()
+}
+
+// mergedOutput returns the gamepad output of the controller in slot, creating the
+// merged gamepad for the first one
+func (m *Manager) mergedOutput(slot int) (Output, error) {
+	m.mergeMu.Lock()
+	defer m.mergeMu.Unlock()
+
+	if m.merged == nil {
+		opts := m.cfg.Gamepad
+		if opts.Bindings == nil {
+			opts.Bindings = m.cfg.Bindings
+		}
+		if opts.Identity == (VirtualIdentity{}) {
+			opts.Identity = m.identityFor("", nil)
+		}
+		virtual, err := NewVirtualGamepad(1, opts)
+		if err != nil {
+			return nil, err
+		}
+		virtual.SetSOCDMode(m.cfg.SOCD)
+		virtual.setClock(m.clock)
+		m.merged = &mergedGamepad{
+			merger:  NewStateMerger(MaxPlayers, m.cfg.MergePolicy, opts.deadzone()),
+			virtual: virtual,
+		}
+		log.Printf("🔀 Merging every controller into one virtual gamepad")
+	}
+	m.merged.users++
+	return &mergeSource{m: m, g: m.merged, source: slot}, nil
+}
@@ -0,0 +1,108 @@
+package procon2
+
+import "testing"
+
+// stickState returns a state with the left stick at (lx, ly) and the right one at (rx, ry)
+func stickState(lx, ly, rx, ry float64) ControllerState {
+	return ControllerState{Joysticks: JoystickValues{LX: lx, LY: ly, RX: rx, RY: ry}}
+}
+
+func TestStateMergerButtonsOR(t *testing.T) {
+	m := NewStateMerger(2, StickLastMoved, 0.1)
+
+	got := m.Update(0, ControllerState{A: true})
+	if !got.A || got.B {
+		t.Fatalf("A from source 0: got A=%v B=%v", got.A, got.B)
+	}
+	got = m.Update(1, ControllerState{B: true})
+	if !got.A || !got.B {
+		t.Fatalf("A and B from two sources: got A=%v B=%v", got.A, got.B)
+	}
+	got = m.Update(1, ControllerState{A: true})
+	if !got.A || got.B {
+		t.Fatalf("A from both, B released: got A=%v B=%v", got.A, got.B)
+	}
+	got = m.Update(0, ControllerState{})
+	if !got.A {
+		t.Fatal("A released on source 0 while source 1 holds it")
+	}
+	got = m.Update(1, ControllerState{})
+	if got.A || got.B {
+		t.Fatalf("everything released: got A=%v B=%v", got.A, got.B)
+	}
+}
+
+func TestStateMergerLastMovedHandoff(t *testing.T) {
+	m := NewStateMerger(2, StickLastMoved, 0.1)
+
+	steps := []struct {
+		name   string
+		source int
+		state  ControllerState
+		wantLX float64
+	}{
+		{"source 0 pushes", 0, stickState(0.8, 0, 0, 0), 0.8},
+		{"source 1 takes over", 1, stickState(-0.5, 0, 0, 0), -0.5},
+		{"source 0 holding still keeps source 1", 0, stickState(0.82, 0, 0, 0), -0.5},
+		{"source 0 moving again takes over", 0, stickState(0.3, 0, 0, 0), 0.3},
+		{"source 1 jitter within the deadzone keeps source 0", 1, stickState(-0.55, 0, 0, 0), 0.3},
+		{"source 0 lets go, hands back to source 1", 0, stickState(0.02, 0, 0, 0), -0.55},
+		// Nobody holds a stick: source 0 moved last, its rest position within the deadzone stays
+		{"source 1 lets go", 1, stickState(0, 0, 0, 0), 0.02},
+	}
+	for _, step := range steps {
+		got := m.Update(step.source, step.state)
+		if got.Joysticks.LX != step.wantLX {
+			t.Fatalf("%s: LX = %v, want %v", step.name, got.Joysticks.LX, step.wantLX)
+		}
+	}
+}
+
+func TestStateMergerBothSticksAtOnce(t *testing.T) {
+	m := NewStateMerger(2, StickLastMoved, 0.1)
+
+	// Each source drives one stick
+	m.Update(0, stickState(0.5, 0, 0, 0))
+	got := m.Update(1, stickState(0, 0, 0, 0.7))
+	if want := stickState(0.5, 0, 0, 0.7).Joysticks; got.Joysticks != want {
+		t.Fatalf("one stick each: got %+v, want %+v", got.Joysticks, want)
+	}
+
+	// Source 1 moves both sticks in the same report, taking the left one over too
+	got = m.Update(1, stickState(-0.6, 0, 0, 0.9))
+	if want := stickState(-0.6, 0, 0, 0.9).Joysticks; got.Joysticks != want {
+		t.Fatalf("source 1 moves both: got %+v, want %+v", got.Joysticks, want)
+	}
+
+	// Source 0 moves both back, while source 1 still holds both
+	got = m.Update(0, stickState(0, 0.4, -0.8, 0))
+	if want := stickState(0, 0.4, -0.8, 0).Joysticks; got.Joysticks != want {
+		t.Fatalf("source 0 moves both: got %+v, want %+v", got.Joysticks, want)
+	}
+
+	// Source 0 lets go of the right stick only, the right stick hands back
+	got = m.Update(0, stickState(0, 0.4, 0, 0))
+	if want := stickState(0, 0.4, 0, 0.9).Joysticks; got.Joysticks != want {
+		t.Fatalf("source 0 lets go of the right stick: got %+v, want %+v", got.Joysticks, want)
+	}
+}
+
+func TestStateMergerPolicies(t *testing.T) {
+	tests := []struct {
+		policy StickPolicy
+		wantLX float64
+	}{
+		{StickAverage, 0.4},      // 0.6 and 0.2, the centered source is ignored
+		{StickMaxMagnitude, 0.6}, // The stick pushed furthest
+		{StickLastMoved, 0.2},    // Source 1 moved last
+	}
+	for _, tt := range tests {
+		m := NewStateMerger(3, tt.policy, 0.1)
+		m.Update(0, stickState(0.6, 0, 0, 0))
+		m.Update(2, stickState(0.02, 0, 0, 0))
+		got := m.Update(1, stickState(0.2, 0, 0, 0))
+		if got.Joysticks.LX != tt.wantLX {
+			t.Errorf("policy %d: LX = %v, want %v", tt.policy, got.Joysticks.LX, tt.wantLX)
+		}
+	}
+}
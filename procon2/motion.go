@@ -0,0 +1,116 @@
+package procon2
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Codes used by VirtualMotion
+const (
+	uiSetPropBit           = 0x4004556e
+	inputPropAccelerometer = 0x06
+
+	absZ  = 0x02
+	absRZ = 0x05
+
+	// Resolutions of the forwarded raw values, in units per G and per degree per second
+	accelResolution = 4096
+	gyroResolution  = 16
+)
+
+// VirtualMotion exposes the IMU as a sensor device next to the gamepad, the way
+// SDL expects controller sensors: accelerometer on ABS_X/Y/Z, gyroscope on ABS_RX/RY/RZ
+type VirtualMotion struct {
+	mu   sync.Mutex
+	file *os.File
+	deviceClock
+}
+
+// NewVirtualMotion creates the motion device of a player
+func NewVirtualMotion(playerNum int) (*VirtualMotion, error) {
+	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /dev/uinput: %w", err)
+	}
+
+	ioctl(f.Fd(), uiSetEvBit, uintptr(evAbs))
+	ioctl(f.Fd(), uiSetEvBit, uintptr(evSyn))
+	ioctl(f.Fd(), uiSetPropBit, uintptr(inputPropAccelerometer))
+
+	axes := []uint16{absX, absY, absZ, absRX, absRY, absRZ}
+	for _, ax := range axes {
+		ioctl(f.Fd(), uiSetAbsBit, uintptr(ax))
+	}
+
+	var usetup uinputSetup
+	name := fmt.Sprintf("%s (Player %d) (IMU)", DRIVER_NAME, playerNum)
+	copy(usetup.name[:], name)
+	usetup.id.bustype = busUsb
+	usetup.id.vendor = PROCON_VENDOR
+	usetup.id.product = 0x2019
+	usetup.id.version = 1
+
+	if err := ioctlSetup(f.Fd(), uiDevSetup, unsafe.Pointer(&usetup)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("UI_DEV_SETUP failed: %w", err)
+	}
+
+	for i, ax := range axes {
+		res := int32(accelResolution)
+		if i >= 3 {
+			res = gyroResolution
+		}
+		if err := setupAbs(f, ax, inputAbsinfo{min: -32768, max: 32767, resolution: res}); err != nil {
+			log.Printf("⚠️ Setting up the motion %s failed (%v), that sensor may read 0", absName(ax), err)
+		}
+	}
+
+	if err := ioctl(f.Fd(), uiDevCreate, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("UI_DEV_CREATE failed: %w", err)
+	}
+	// f.Fd() switched f to blocking mode, writes handle EAGAIN themselves
+	syscall.SetNonblock(int(f.Fd()), true)
+
+	return &VirtualMotion{file: f}, nil
+}
+
+// Update forwards the IMU sample of a state, states without one are ignored
+func (m *VirtualMotion) Update(state ControllerState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		return fmt.Errorf("virtual motion device closed")
+	}
+	mv := state.Motion
+	if !mv.Valid {
+		return nil
+	}
+	return writeInputEvents(m.file, []inputEvent{
+		{typ: evAbs, code: absX, value: int32(mv.AccelX)},
+		{typ: evAbs, code: absY, value: int32(mv.AccelY)},
+		{typ: evAbs, code: absZ, value: int32(mv.AccelZ)},
+		{typ: evAbs, code: absRX, value: int32(mv.GyroX)},
+		{typ: evAbs, code: absRY, value: int32(mv.GyroY)},
+		{typ: evAbs, code: absRZ, value: int32(mv.GyroZ)},
+		{typ: evSyn},
+	}, m.clock)
+}
+
+func (m *VirtualMotion) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file != nil {
+		ioctl(m.file.Fd(), uiDevDestroy, 0)
+		err := m.file.Close()
+		m.file = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package procon2
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Linux codes used by VirtualMouse
+const (
+	btnLeft   = 0x110
+	btnRight  = 0x111
+	btnMiddle = 0x112
+
+	relX     = 0x00
+	relY     = 0x01
+	relWheel = 0x08
+)
+
+// MouseOptions tunes how a VirtualMouse moves
+type MouseOptions struct {
+	Speed       float64 // Pointer pixels per report at full deflection of the right stick
+	ScrollSpeed float64 // Wheel notches per report at full deflection of the left stick
+	Deadzone    float64 // Normalized stick deflection ignored around the center
+	// InvertY moves the pointer down when the right stick is pushed up
+	InvertY bool
+	// NaturalScroll scrolls down when the left stick is pushed up, like touchpads
+	// with natural scrolling move the content along with the fingers
+	NaturalScroll bool
+}
+
+// DefaultMouseOptions moves the pointer at about 1000 pixels per second with 8ms reports
+var DefaultMouseOptions = MouseOptions{
+	Speed:       8.0,
+	ScrollSpeed: 0.1,
+	Deadzone:    0.1,
+}
+
+// VirtualMouse moves the pointer with the right stick and scrolls with the left one.
+// ZR is the left click, ZL the right click and the right stick press the middle click.
+type VirtualMouse struct {
+	mu   sync.Mutex
+	file *os.File
+	opts MouseOptions
+
+	// Sub-pixel movement carried over to the next report
+	restX, restY, restWheel float64
+	pending                 []inputEvent // Events of the frame being built, see sendSync
+	deviceClock
+}
+
+// NewVirtualMouse creates a virtual mouse
+func NewVirtualMouse(playerNum int, opts MouseOptions) (*VirtualMouse, error) {
+	f, err := newUinputDevice(fmt.Sprintf("%s Mouse (Player %d)", DRIVER_NAME, playerNum),
+		[]uint16{btnLeft, btnRight, btnMiddle}, []uint16{relX, relY, relWheel})
+	if err != nil {
+		return nil, err
+	}
+	return &VirtualMouse{file: f, opts: opts}, nil
+}
+
+func (m *VirtualMouse) Update(state ControllerState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		return fmt.Errorf("virtual mouse closed")
+	}
+	return m.update(state)
+}
+
+func (m *VirtualMouse) update(state ControllerState) error {
+	m.sendButton(btnLeft, state.ZR)
+	m.sendButton(btnRight, state.ZL)
+	m.sendButton(btnMiddle, state.RStickPress)
+
+	// Positive Y is up on normalized values but down for the pointer, and up for the wheel
+	pointerY, wheel := -1.0, 1.0
+	if m.opts.InvertY {
+		pointerY = 1
+	}
+	if m.opts.NaturalScroll {
+		wheel = -1
+	}
+	m.restX = m.move(relX, m.restX+m.deadzone(state.Joysticks.RX)*m.opts.Speed)
+	m.restY = m.move(relY, m.restY+pointerY*m.deadzone(state.Joysticks.RY)*m.opts.Speed)
+	m.restWheel = m.move(relWheel, m.restWheel+wheel*m.deadzone(state.Joysticks.LY)*m.opts.ScrollSpeed)
+
+	return m.sendSync()
+}
+
+// sendSync ends the frame and writes all its events at once
+func (m *VirtualMouse) sendSync() error {
+	m.pending = append(m.pending, inputEvent{typ: evSyn})
+	err := writeInputEvents(m.file, m.pending, m.clock)
+	m.pending = m.pending[:0]
+	return err
+}
+
+// move sends the whole part of amount and returns the remainder
+func (m *VirtualMouse) move(code uint16, amount float64) float64 {
+	whole := int32(amount)
+	if whole != 0 {
+		m.pending = append(m.pending, inputEvent{typ: evRel, code: code, value: whole})
+	}
+	return amount - float64(whole)
+}
+
+func (m *VirtualMouse) deadzone(value float64) float64 {
+	if value > -m.opts.Deadzone && value < m.opts.Deadzone {
+		return 0.0
+	}
+	return value
+}
+
+func (m *VirtualMouse) sendButton(code uint16, pressed bool) {
+	val := int32(0)
+	if pressed {
+		val = 1
+	}
+	m.pending = append(m.pending, inputEvent{typ: evKey, code: code, value: val})
+}
+
+func (m *VirtualMouse) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file != nil {
+		m.sendButton(btnLeft, false)
+		m.sendButton(btnRight, false)
+		m.sendButton(btnMiddle, false)
+		m.sendSync()
+
+		ioctl(m.file.Fd(), uiDevDestroy, 0)
+		err := m.file.Close()
+		m.file = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package procon2
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/gousb"
+)
+
+// OpenOptions tunes OpenBySerialWithOptions
+type OpenOptions struct {
+	// Match selects the devices considered, IsProController when nil
+	Match func(*gousb.DeviceDesc) bool
+	// Init is passed to SendInitSequenceWithOptions
+	Init InitOptions
+	// ReadyTimeout bounds the wait for the first full report after init
+	ReadyTimeout time.Duration
+	// Calibration normalizes the sticks read by the HIDReader
+	Calibration JoystickCalibration
+	// Reader configures the HIDReader
+	Reader HIDReaderOptions
+	// Logger receives the log lines of the controller, the standard logger when nil
+	Logger Logger
+}
+
+// DefaultOpenOptions initializes controllers like the Manager does by default
+var DefaultOpenOptions = OpenOptions{
+	Init:         InitOptions{MaxFailRatio: DefaultConfig.InitFailRatio},
+	ReadyTimeout: DefaultConfig.ReadyTimeout,
+	Calibration:  DefaultCalibration,
+	Reader:       DefaultHIDReaderOptions,
+}
+
+// ListSerials returns the serial numbers of the connected controllers IsProController matches
+func ListSerials() ([]string, error) {
+	ctx := gousb.NewContext()
+	defer ctx.Close()
+
+	devs, err := ctx.OpenDevices(IsProController)
+	defer func() {
+		for _, dev := range devs {
+			dev.Close()
+		}
+	}()
+	if err != nil && len(devs) == 0 {
+		return nil, fmt.Errorf("enumerate USB devices: %w", err)
+	}
+	serials := make([]string, 0, len(devs))
+	for _, dev := range devs {
+		if serial, err := dev.SerialNumber(); err == nil {
+			serials = append(serials, serial)
+		}
+	}
+	return serials, nil
+}
+
+// OpenBySerial initializes the controller with this serial number and starts reading it,
+// without a Manager: no virtual device, slot or LED is set up. Close the reader, then the
+// controller, which also closes the USB device and context.
+func OpenBySerial(serial string) (*Controller, *HIDReader, error) {
+	return OpenBySerialWithOptions(serial, DefaultOpenOptions)
+}
+
+// OpenBySerialWithOptions is OpenBySerial with chosen options
+func OpenBySerialWithOptions(serial string, opts OpenOptions) (*Controller, *HIDReader, error) {
+	match := opts.Match
+	if match == nil {
+		match = IsProController
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = stdLogger{}
+	}
+
+	ctx := gousb.NewContext()
+	devs, err := ctx.OpenDevices(match)
+	var dev *gousb.Device
+	for _, d := range devs {
+		if s, serr := d.SerialNumber(); serr == nil && s == serial && dev == nil {
+			dev = d
+			continue
+		}
+		d.Close()
+	}
+	if dev == nil {
+		ctx.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("no controller with serial %q: %w", serial, err)
+		}
+		return nil, nil, fmt.Errorf("no controller with serial %q", serial)
+	}
+
+	iface := USBInterfaceFor(dev.Desc)
+	ctrl, err := NewControllerWithLogger(dev, iface.Config, iface.Interface, logger)
+	if err != nil {
+		dev.Close()
+		ctx.Close()
+		return nil, nil, err
+	}
+	// The controller opened them, it closes them
+	ctrl.owned = []io.Closer{dev, ctx}
+
+	if !ctrl.ReadOnly() {
+		if err := ctrl.SendInitSequenceWithOptions(opts.Init); err != nil {
+			ctrl.Close()
+			return nil, nil, fmt.Errorf("init failed: %w", err)
+		}
+	}
+	if ctrl.GetHIDPath() == "" {
+		ctrl.Close()
+		return nil, nil, fmt.Errorf("no HID path found")
+	}
+	if !ctrl.ReadOnly() {
+		if err := ctrl.WaitForFullReport(opts.ReadyTimeout); err != nil {
+			ctrl.Close()
+			return nil, nil, fmt.Errorf("init handshake failed: %w", err)
+		}
+	}
+
+	readerOpts := opts.Reader
+	if readerOpts.Profile == nil {
+		readerOpts.Profile = ctrl.Profile()
+	}
+	reader, err := NewHIDReaderWithOptions(ctrl.GetHIDPath(), opts.Calibration, readerOpts)
+	if err != nil {
+		ctrl.Close()
+		return nil, nil, err
+	}
+	if err := reader.WaitReady(opts.ReadyTimeout); err != nil {
+		logger.Printf("⚠️ Not responding yet: %v", err)
+	}
+	return ctrl, reader, nil
+}
@@ -0,0 +1,218 @@
+package procon2
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Output is a backend fed with every controller state of a Driver
+type Output interface {
+	Update(state ControllerState) error
+	Close() error
+}
+
+// OutputKind selects an output backend
+type OutputKind int
+
+const (
+	OutputGamepad  OutputKind = iota // VirtualGamepad
+	OutputKeyboard                   // VirtualKeyboard
+	OutputMouse                      // VirtualMouse
+	OutputMotion                     // VirtualMotion, a separate sensor device
+)
+
+var outputKindNames = map[OutputKind]string{
+	OutputGamepad:  "gamepad",
+	OutputKeyboard: "keyboard",
+	OutputMouse:    "mouse",
+	OutputMotion:   "motion",
+}
+
+// ParseOutputKinds parses a comma separated list of backends, e.g. "gamepad,mouse"
+func ParseOutputKinds(spec string) ([]OutputKind, error) {
+	var kinds []OutputKind
+	seen := make(map[OutputKind]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		kind, ok := OutputKind(-1), false
+		for k, n := range outputKindNames {
+			if strings.EqualFold(n, item) {
+				kind, ok = k, true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown output %q (expected gamepad, keyboard, mouse or motion)", item)
+		}
+		if !seen[kind] {
+			seen[kind] = true
+			kinds = append(kinds, kind)
+		}
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("no output selected")
+	}
+	return kinds, nil
+}
+
+// ParseOutputSpec parses comma separated [SERIAL=]OUTPUT items, with OUTPUT one backend
+// or several joined by '+', e.g. "gamepad,XYZ123=keyboard+mouse". Items without a serial
+// are the outputs of every other controller, a gamepad when there are none.
+func ParseOutputSpec(spec string) ([]OutputKind, map[string][]OutputKind, error) {
+	var common []string
+	bySerial := make(map[string][]OutputKind)
+	for _, item := range strings.Split(spec, ",") {
+		serial, kinds, ok := strings.Cut(item, "=")
+		if !ok {
+			common = append(common, item)
+			continue
+		}
+		serial = strings.TrimSpace(serial)
+		if serial == "" {
+			return nil, nil, fmt.Errorf("empty serial in %q", item)
+		}
+		parsed, err := ParseOutputKinds(strings.ReplaceAll(kinds, "+", ","))
+		if err != nil {
+			return nil, nil, fmt.Errorf("outputs of %s: %w", serial, err)
+		}
+		bySerial[serial] = parsed
+	}
+
+	defaults := []OutputKind{OutputGamepad}
+	if strings.TrimSpace(strings.Join(common, "")) != "" {
+		var err error
+		if defaults, err = ParseOutputKinds(strings.Join(common, ",")); err != nil {
+			return nil, nil, err
+		}
+	}
+	return defaults, bySerial, nil
+}
+
+func (k OutputKind) String() string {
+	if n, ok := outputKindNames[k]; ok {
+		return n
+	}
+	return "unknown"
+}
+
+// newUinputDevice creates a uinput device exposing the given key and relative axis codes
+func newUinputDevice(name string, keys, rels []uint16) (*os.File, error) {
+	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /dev/uinput: %w", err)
+	}
+
+	ioctl(f.Fd(), uiSetEvBit, uintptr(evKey))
+	ioctl(f.Fd(), uiSetEvBit, uintptr(evSyn))
+	for _, key := range keys {
+		ioctl(f.Fd(), uiSetKeyBit, uintptr(key))
+	}
+	if len(rels) > 0 {
+		ioctl(f.Fd(), uiSetEvBit, uintptr(evRel))
+		for _, rel := range rels {
+			ioctl(f.Fd(), uiSetRelBit, uintptr(rel))
+		}
+	}
+
+	var usetup uinputSetup
+	copy(usetup.name[:], name)
+	usetup.id.bustype = busUsb
+	usetup.id.vendor = PROCON_VENDOR
+	usetup.id.product = 0x2019
+	usetup.id.version = 1
+
+	if err := ioctlSetup(f.Fd(), uiDevSetup, unsafe.Pointer(&usetup)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("UI_DEV_SETUP failed: %w", err)
+	}
+	if err := ioctl(f.Fd(), uiDevCreate, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("UI_DEV_CREATE failed: %w", err)
+	}
+	// f.Fd() switched f to blocking mode, writes handle EAGAIN themselves
+	syscall.SetNonblock(int(f.Fd()), true)
+	return f, nil
+}
+
+// uinputWriteTimeout is how long a write to a uinput device that is not accepting
+// events (EAGAIN) is retried before the events are given up
+const uinputWriteTimeout = 20 * time.Millisecond
+
+// uinputRetryInterval is the pause between those retries
+const uinputRetryInterval = 250 * time.Microsecond
+
+// deviceClock is embedded by the uinput devices to time their write retries, RealClock
+// until the Manager sets its own
+type deviceClock struct {
+	clock Clock
+}
+
+func (d *deviceClock) setClock(c Clock) { d.clock = c }
+
+// clockSetter is a device taking the Manager's Clock, see deviceClock
+type clockSetter interface {
+	setClock(c Clock)
+}
+
+// writeInputEvents timestamps events and writes them with a single write. The devices are
+// non-blocking, so a write the kernel can't take yet is retried for uinputWriteTimeout on
+// clock, and the events it still could not write are reported as an error.
+func writeInputEvents(f *os.File, events []inputEvent, clock Clock) error {
+	if len(events) == 0 {
+		return nil
+	}
+	var tv syscall.Timeval
+	syscall.Gettimeofday(&tv)
+	for i := range events {
+		events[i].time = tv
+	}
+	eventSize := int(unsafe.Sizeof(events[0]))
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&events[0])), len(events)*eventSize)
+	// Unlike f.Fd(), Control leaves the file in non-blocking mode
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("uinput write: %w", err)
+	}
+	var werr error
+	if err := conn.Control(func(fd uintptr) { werr = writeAllEvents(int(fd), buf, eventSize, clockOrReal(clock)) }); err != nil {
+		return fmt.Errorf("uinput write: %w", err)
+	}
+	return werr
+}
+
+// writeAllEvents writes buf, events of eventSize bytes, retrying for uinputWriteTimeout
+// while the device is not taking them
+func writeAllEvents(fd int, buf []byte, eventSize int, clock Clock) error {
+	total := len(buf) / eventSize
+	var deadline time.Time
+	for len(buf) > 0 {
+		n, err := syscall.Write(fd, buf)
+		if n > 0 {
+			// uinput takes whole events, the rest goes in the next write
+			buf = buf[n:]
+		}
+		switch {
+		case err == nil && n > 0, errors.Is(err, syscall.EINTR):
+			continue
+		case err == nil, errors.Is(err, syscall.EAGAIN):
+			if deadline.IsZero() {
+				deadline = clock.Now().Add(uinputWriteTimeout)
+			} else if clock.Now().After(deadline) {
+				return fmt.Errorf("uinput write: %d of %d events dropped after %v: %w",
+					len(buf)/eventSize, total, uinputWriteTimeout, syscall.EAGAIN)
+			}
+			clock.Sleep(uinputRetryInterval)
+		default:
+			return fmt.Errorf("uinput write: %w", err)
+		}
+	}
+	return nil
+}
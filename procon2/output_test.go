@@ -0,0 +1,37 @@
+package procon2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOutputSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		defaults []OutputKind
+		bySerial map[string][]OutputKind
+	}{
+		{"gamepad,mouse", []OutputKind{OutputGamepad, OutputMouse}, map[string][]OutputKind{}},
+		{"XYZ123=keyboard+mouse", []OutputKind{OutputGamepad}, map[string][]OutputKind{"XYZ123": {OutputKeyboard, OutputMouse}}},
+		{"mouse, XYZ123=gamepad+motion, ABC=keyboard", []OutputKind{OutputMouse}, map[string][]OutputKind{
+			"XYZ123": {OutputGamepad, OutputMotion},
+			"ABC":    {OutputKeyboard},
+		}},
+	}
+	for _, tt := range tests {
+		defaults, bySerial, err := ParseOutputSpec(tt.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(defaults, tt.defaults) || !reflect.DeepEqual(bySerial, tt.bySerial) {
+			t.Errorf("%q: got %v %v, want %v %v", tt.spec, defaults, bySerial, tt.defaults, tt.bySerial)
+		}
+	}
+
+	for _, spec := range []string{"XYZ123=", "=mouse", "XYZ123=gamepad+joystick", "gamepad,speaker"} {
+		if _, _, err := ParseOutputSpec(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package procon2
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JoystickProcessing bundles the settings shaping how sticks feel: the normalized
+// deadzone, as in GamepadOptions.Deadzone, the response curve of both sticks and the clamp
+type JoystickProcessing struct {
+	Deadzone float64
+	Curve    ResponseCurve
+	Clamp    StickClamp
+}
+
+// JoystickPresets are ready-made JoystickProcessing settings, selected by name
+var JoystickPresets = map[string]JoystickProcessing{
+	// What the driver does without a preset
+	"default": {Deadzone: DefaultGamepadOptions.Deadzone, Clamp: StickClampSquare},
+	// Small deadzone and a quick ramp, small movements already turn fast
+	"aggressive-aim": {
+		Deadzone: 0.03,
+		Curve:    ResponseCurve{{0, 0}, {0.3, 0.45}, {1, 1}},
+		Clamp:    StickClampSquare,
+	},
+	// Larger deadzone and a gentle start, for precise aiming and worn sticks
+	"smooth": {
+		Deadzone: 0.08,
+		Curve:    ResponseCurve{{0, 0}, {0.5, 0.25}, {1, 1}},
+		Clamp:    StickClampCircle,
+	},
+	// Sticks act like a D-pad: centered or fully pushed, like old digital games expect
+	"retro-digital": {
+		Deadzone: 0.25,
+		Curve:    ResponseCurve{{0, 0}, {0.3, 0}, {0.35, 1}, {1, 1}},
+		Clamp:    StickClampSquare,
+	},
+}
+
+// ParseJoystickPreset returns the preset with this name, see JoystickPresets
+func ParseJoystickPreset(name string) (JoystickProcessing, error) {
+	if p, ok := JoystickPresets[name]; ok {
+		return p, nil
+	}
+	names := make([]string, 0, len(JoystickPresets))
+	for n := range JoystickPresets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return JoystickProcessing{}, fmt.Errorf("unknown preset %q (expected %s)", name, strings.Join(names, ", "))
+}
+
+// Apply sets the stick settings of cfg, for every controller
+func (p JoystickProcessing) Apply(cfg *Config) {
+	cfg.Gamepad.Deadzone = p.Deadzone
+	cfg.Gamepad.LeftCurve, cfg.Gamepad.RightCurve = p.Curve, p.Curve
+	cfg.StickClamp = p.Clamp
+}
@@ -0,0 +1,130 @@
+package procon2
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/gousb"
+)
+
+// ParseProductIDs parses a comma or whitespace separated list of product IDs,
+// in hex with or without 0x, e.g. "0x2069,20aa"
+func ParseProductIDs(spec string) ([]gousb.ID, error) {
+	var ids []gousb.ID
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	}) {
+		v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(item), "0x"), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid product ID %q", item)
+		}
+		ids = append(ids, gousb.ID(v))
+	}
+	return ids, nil
+}
+
+// LoadProductIDs reads a file written by SaveProductIDs. A missing file is an empty list.
+func LoadProductIDs(path string) ([]gousb.ID, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids, err := ParseProductIDs(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return ids, nil
+}
+
+// SaveProductIDs writes one product ID per line, replacing the file atomically
+func SaveProductIDs(path string, ids []gousb.ID) error {
+	var b strings.Builder
+	for _, id := range ids {
+		fmt.Fprintf(&b, "0x%04x\n", uint16(id))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// productSet is an immutable set of product IDs, replaced as a whole when one is added
+type productSet map[gousb.ID]bool
+
+// sorted returns the IDs in ascending order
+func (s productSet) sorted() []gousb.ID {
+	ids := make([]gousb.ID, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// loadProducts builds the initial extra product set from the config and its file
+func (m *Manager) loadProducts() {
+	set := make(productSet)
+	for _, id := range m.cfg.ExtraProducts {
+		set[id] = true
+	}
+	if m.cfg.ProductsFile != "" {
+		ids, err := LoadProductIDs(m.cfg.ProductsFile)
+		if err != nil {
+			log.Printf("⚠️ Ignoring product IDs file: %v", err)
+		}
+		for _, id := range ids {
+			set[id] = true
+		}
+	}
+	m.products.Store(&set)
+}
+
+// AddProduct makes scans pick up a Nintendo product ID the driver doesn't know, e.g. a
+// new clone, without a restart. It is saved to Config.ProductsFile when one is set.
+func (m *Manager) AddProduct(id gousb.ID) error {
+	m.productsMu.Lock()
+	defer m.productsMu.Unlock()
+
+	old := *m.products.Load()
+	if old[id] {
+		return nil
+	}
+	set := make(productSet, len(old)+1)
+	for k := range old {
+		set[k] = true
+	}
+	set[id] = true
+	m.products.Store(&set)
+	log.Printf("➕ Accepting product ID 0x%04x, replug the controller if it is already connected", uint16(id))
+
+	if m.cfg.ProductsFile == "" {
+		return nil
+	}
+	if err := SaveProductIDs(m.cfg.ProductsFile, set.sorted()); err != nil {
+		return fmt.Errorf("saving product IDs: %w", err)
+	}
+	return nil
+}
+
+// Products returns the product IDs accepted on top of the built-in ones
+func (m *Manager) Products() []gousb.ID {
+	return m.products.Load().sorted()
+}
+
+// isExtraProduct reports whether desc is a Nintendo device added with AddProduct or the config
+func (m *Manager) isExtraProduct(desc *gousb.DeviceDesc) bool {
+	return desc.Vendor == gousb.ID(PROCON_VENDOR) && (*m.products.Load())[desc.Product]
+}
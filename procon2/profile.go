@@ -0,0 +1,241 @@
+package procon2
+
+import (
+	"time"
+
+	"github.com/google/gousb"
+)
+
+// ModelProfile is how a controller model is initialized, parsed and rumbled
+type ModelProfile struct {
+	Name string
+
+	// InitPackets are written as is to the OUT endpoint by SendInitSequence
+	InitPackets [][]byte
+	// InitSubcommands are sent after InitPackets, as SubcommandReport output reports
+	InitSubcommands []InitSubcommand
+	// InitPacketDelay is the pause after each init packet and subcommand
+	InitPacketDelay time.Duration
+	// VerifyPackets are the indexes in InitPackets that must get a reply, accepted by InitAck
+	// (any reply when nil), before the next packet goes out. See InitOptions.Verify.
+	VerifyPackets []int
+	InitAck       func(packet, reply []byte) bool
+
+	// ReleaseSubcommands then ReleasePackets put the controller back in the mode the
+	// kernel driver expects, see Controller.Release
+	ReleaseSubcommands []InitSubcommand
+	ReleasePackets     [][]byte
+
+	// SubcommandReport is the output report carrying subcommands such as the LEDs one,
+	// SubcommandReply the input report answering them
+	SubcommandReport byte
+	SubcommandReply  byte
+	// RumblePrefix is the neutral rumble data preceding the subcommand in SubcommandReport,
+	// left zeroed while rumble is off
+	RumblePrefix [8]byte
+
+	// Grab decides whether the kernel evdev node of the controller is grabbed
+	Grab GrabPolicy
+
+	// Identity is what its virtual gamepad presents as, IdentityProController when unset.
+	// Config.Identities overrides it.
+	Identity VirtualIdentity
+
+	// Haptics is the rumble frame layout, TestPattern a short pattern in that layout
+	Haptics     HapticFormat
+	TestPattern HapticPattern
+
+	// parseButtons decodes the 3 button bytes of a full report into state
+	parseButtons func(rep []byte, state *ControllerState)
+}
+
+// GrabPolicy decides whether the kernel evdev node of a controller is grabbed, hiding it
+// from games so they don't see the controller twice
+type GrabPolicy int
+
+const (
+	GrabTry    GrabPolicy = iota // Grab when the node is found, going on without it otherwise
+	GrabAlways                   // Fail the controller when the node can't be grabbed
+	GrabNever                    // Don't look for the node, e.g. when the kernel creates none
+)
+
+func (g GrabPolicy) String() string {
+	switch g {
+	case GrabTry:
+		return "try"
+	case GrabAlways:
+		return "always"
+	case GrabNever:
+		return "never"
+	}
+	return "unknown"
+}
+
+// InitSubcommand is a subcommand sent while initializing
+type InitSubcommand struct {
+	ID   byte
+	Data []byte
+	// Rumble marks a subcommand turning the vibration motors on. It is skipped while
+	// rumble is off, and sent with zeroed data to turn them off, see Controller.SwitchRumble.
+	Rumble bool
+}
+
+// neutralRumblePrefix is HD rumble silence for both motors
+var neutralRumblePrefix = [8]byte{0x00, 0x01, 0x40, 0x40, 0x00, 0x01, 0x40, 0x40}
+
+// ProController2Profile drives the Switch 2 Pro Controller, and any product without a profile
+var ProController2Profile = &ModelProfile{
+	Name: "Pro Controller 2",
+	// Preserving your original sequence for compatibility with your device
+	InitPackets: [][]byte{
+		{0x03, 0x91, 0x00, 0x0d, 0x00, 0x08, 0x00, 0x00, 0x01, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		{0x07, 0x91, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+		{0x16, 0x91, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+		{0x15, 0x91, 0x00, 0x01, 0x00, 0x0e, 0x00, 0x00, 0x00, 0x02, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		{0x15, 0x91, 0x00, 0x02, 0x00, 0x11, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		{0x15, 0x91, 0x00, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00},
+		{0x09, 0x91, 0x00, 0x07, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x0c, 0x91, 0x00, 0x02, 0x00, 0x04, 0x00, 0x00, 0x27, 0x00, 0x00, 0x00},
+		{0x11, 0x91, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00},
+		{0x0a, 0x91, 0x00, 0x08, 0x00, 0x14, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x35, 0x00, 0x46, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x0c, 0x91, 0x00, 0x04, 0x00, 0x04, 0x00, 0x00, 0x27, 0x00, 0x00, 0x00},
+		{0x03, 0x91, 0x00, 0x0a, 0x00, 0x04, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00},
+		{0x10, 0x91, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+		{0x01, 0x91, 0x00, 0x0c, 0x00, 0x00, 0x00, 0x00},
+		{0x03, 0x91, 0x00, 0x01, 0x00, 0x00, 0x00},
+		{0x0a, 0x91, 0x00, 0x02, 0x00, 0x04, 0x00, 0x00, 0x03, 0x00, 0x00},
+		{0x09, 0x91, 0x00, 0x07, 0x00, 0x08, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	},
+	InitPacketDelay:  15 * time.Millisecond,
+	SubcommandReport: 0x01,
+	SubcommandReply:  0x21,
+	RumblePrefix:     neutralRumblePrefix,
+	Grab:             GrabTry,
+	Haptics:          HapticFormatSwitch2,
+	TestPattern:      DefaultHapticPattern,
+	parseButtons:     parseButtonsSwitch2,
+}
+
+// Switch1Profile drives the original Switch Pro Controller, which speaks the Joy-Con
+// protocol: a USB handshake, then subcommands, the standard 0x30 button layout and HD rumble
+var Switch1Profile = &ModelProfile{
+	Name: "Switch Pro Controller",
+	InitPackets: [][]byte{
+		{0x80, 0x02}, // Handshake
+		{0x80, 0x03}, // Switch to 3Mbit baud rate
+		{0x80, 0x02}, // Handshake again at the new rate
+		{0x80, 0x04}, // Talk over USB only, without the Bluetooth timeout
+	},
+	InitSubcommands: []InitSubcommand{
+		{ID: 0x03, Data: []byte{0x30}},               // Full report mode
+		{ID: 0x48, Data: []byte{0x01}, Rumble: true}, // Enable vibration
+	},
+	InitPacketDelay: 15 * time.Millisecond,
+	// The handshakes are answered with 0x81 and the same command, the controller
+	// ignores what follows until it did
+	VerifyPackets: []int{0, 2},
+	InitAck:       switch1InitAck,
+	ReleaseSubcommands: []InitSubcommand{
+		{ID: 0x03, Data: []byte{0x3F}}, // Simple HID report mode
+	},
+	ReleasePackets: [][]byte{
+		{0x80, 0x05}, // Undo the USB only mode of the init sequence
+	},
+	SubcommandReport: 0x01,
+	SubcommandReply:  0x21,
+	RumblePrefix:     neutralRumblePrefix,
+	Grab:             GrabTry,
+	Haptics:          HapticFormatSwitch1,
+	TestPattern:      DefaultSwitch1HapticPattern,
+	parseButtons:     parseButtonsSwitch1,
+}
+
+// productProfiles lists the products that don't use ProController2Profile
+var productProfiles = map[gousb.ID]*ModelProfile{
+	0x2009: Switch1Profile,
+}
+
+// ProfileFor returns the profile of a device from its product ID
+func ProfileFor(desc *gousb.DeviceDesc) *ModelProfile {
+	if p, ok := productProfiles[desc.Product]; ok {
+		return p
+	}
+	return ProController2Profile
+}
+
+func (p *ModelProfile) String() string {
+	return p.Name
+}
+
+// switch1InitAck accepts the 0x81 reply echoing a 0x80 USB command
+func switch1InitAck(packet, reply []byte) bool {
+	return len(reply) >= 2 && len(packet) >= 2 && reply[0] == 0x81 && reply[1] == packet[1]
+}
+
+// parseButtonsSwitch2 decodes the Pro Controller 2 button bytes
+func parseButtonsSwitch2(rep []byte, state *ControllerState) {
+	if len(rep) > 3 {
+		b3 := rep[3]
+		state.B = (b3 & 0x01) != 0
+		state.A = (b3 & 0x02) != 0
+		state.Y = (b3 & 0x04) != 0
+		state.X = (b3 & 0x08) != 0
+		state.R = (b3 & 0x10) != 0
+		state.ZR = (b3 & 0x20) != 0
+		state.Plus = (b3 & 0x40) != 0
+		state.RStickPress = (b3 & 0x80) != 0
+	}
+
+	if len(rep) > 4 {
+		b4 := rep[4]
+		state.DpadDown = (b4 & 0x01) != 0
+		state.DpadRight = (b4 & 0x02) != 0
+		state.DpadLeft = (b4 & 0x04) != 0
+		state.DpadUp = (b4 & 0x08) != 0
+		state.L = (b4 & 0x10) != 0
+		state.ZL = (b4 & 0x20) != 0
+		state.Minus = (b4 & 0x40) != 0
+		state.LStickPress = (b4 & 0x80) != 0
+	}
+
+	if len(rep) > 5 {
+		b5 := rep[5]
+		state.Home = (b5 & 0x01) != 0
+		state.Capture = (b5 & 0x02) != 0
+		state.PaddleRight = (b5 & 0x04) != 0
+		state.PaddleLeft = (b5 & 0x08) != 0
+	}
+}
+
+// parseButtonsSwitch1 decodes the standard 0x30 button bytes: right, shared, then left
+func parseButtonsSwitch1(rep []byte, state *ControllerState) {
+	if len(rep) > 3 {
+		b3 := rep[3]
+		state.Y = (b3 & 0x01) != 0
+		state.X = (b3 & 0x02) != 0
+		state.B = (b3 & 0x04) != 0
+		state.A = (b3 & 0x08) != 0
+		state.R = (b3 & 0x40) != 0
+		state.ZR = (b3 & 0x80) != 0
+	}
+
+	if len(rep) > 4 {
+		b4 := rep[4]
+		state.Minus = (b4 & 0x01) != 0
+		state.Plus = (b4 & 0x02) != 0
+		state.RStickPress = (b4 & 0x04) != 0
+		state.LStickPress = (b4 & 0x08) != 0
+		state.Home = (b4 & 0x10) != 0
+		state.Capture = (b4 & 0x20) != 0
+	}
+
+	if len(rep) > 5 {
+		b5 := rep[5]
+		state.DpadDown = (b5 & 0x01) != 0
+		state.DpadUp = (b5 & 0x02) != 0
+		state.DpadRight = (b5 & 0x04) != 0
+		state.DpadLeft = (b5 & 0x08) != 0
+		state.L = (b5 & 0x40) != 0
+		state.ZL = (b5 & 0x80) != 0
+	}
+}
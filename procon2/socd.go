@@ -0,0 +1,121 @@
+package procon2
+
+import "fmt"
+
+// SOCDMode selects how simultaneous opposing D-pad directions are resolved
+type SOCDMode int
+
+const (
+	SOCDOff        SOCDMode = iota // Forward both directions as reported
+	SOCDNeutral                    // Opposing directions cancel each other out
+	SOCDLastWins                   // The most recently pressed direction wins
+	SOCDUpPriority                 // Up wins over Down, Left+Right is neutral
+)
+
+// ParseSOCDMode converts a mode name as used on the command line
+func ParseSOCDMode(name string) (SOCDMode, error) {
+	switch name {
+	case "off":
+		return SOCDOff, nil
+	case "neutral":
+		return SOCDNeutral, nil
+	case "last-wins":
+		return SOCDLastWins, nil
+	case "up-priority":
+		return SOCDUpPriority, nil
+	}
+	return SOCDOff, fmt.Errorf("unknown SOCD mode %q (expected off, neutral, last-wins or up-priority)", name)
+}
+
+// socdAxis resolves one pair of opposing directions, remembering which was pressed last
+type socdAxis struct {
+	prevFirst, prevSecond bool
+	firstLast             bool
+}
+
+// resolve returns the directions to forward; firstPriority tells whether
+// the first direction wins in SOCDUpPriority mode
+func (a *socdAxis) resolve(mode SOCDMode, first, second, firstPriority bool) (bool, bool) {
+	if first && !a.prevFirst {
+		a.firstLast = true
+	}
+	if second && !a.prevSecond {
+		a.firstLast = false
+	}
+	a.prevFirst, a.prevSecond = first, second
+
+	if !first || !second {
+		return first, second
+	}
+
+	switch mode {
+	case SOCDOff:
+		return true, true
+	case SOCDLastWins:
+		return a.firstLast, !a.firstLast
+	case SOCDUpPriority:
+		return firstPriority, false
+	default:
+		return false, false
+	}
+}
+
+// HatSOCDMode selects how the D-pad resolves into a single hat value when it is forwarded
+// as a hat, independently from SOCDMode which only concerns the D-pad buttons
+type HatSOCDMode int
+
+const (
+	HatSOCDNeutral         HatSOCDMode = iota // Opposing directions cancel each other out, diagonals are kept
+	HatSOCDLastInput                          // The most recently pressed of opposing directions wins, diagonals are kept
+	HatSOCDFavorHorizontal                    // Opposing directions cancel, a diagonal becomes its horizontal direction
+	HatSOCDFavorVertical                      // Opposing directions cancel, a diagonal becomes its vertical direction
+)
+
+// ParseHatSOCDMode converts a mode name as used on the command line
+func ParseHatSOCDMode(name string) (HatSOCDMode, error) {
+	switch name {
+	case "neutral":
+		return HatSOCDNeutral, nil
+	case "last-input":
+		return HatSOCDLastInput, nil
+	case "favor-horizontal":
+		return HatSOCDFavorHorizontal, nil
+	case "favor-vertical":
+		return HatSOCDFavorVertical, nil
+	}
+	return HatSOCDNeutral, fmt.Errorf("unknown hat SOCD mode %q (expected neutral, last-input, favor-horizontal or favor-vertical)", name)
+}
+
+// resolveHat returns the hat X and Y values for the D-pad of state. x and y remember the
+// last pressed directions for HatSOCDLastInput. Both values are computed from the same
+// state and go out in the same frame, so moving between a diagonal and a cardinal
+// direction never passes through the center.
+func resolveHat(mode HatSOCDMode, x, y *socdAxis, state ControllerState) (int32, int32) {
+	socd := SOCDNeutral
+	if mode == HatSOCDLastInput {
+		socd = SOCDLastWins
+	}
+	left, right := x.resolve(socd, state.DpadLeft, state.DpadRight, false)
+	up, down := y.resolve(socd, state.DpadUp, state.DpadDown, true)
+	hx, hy := hatValue(left, right), hatValue(up, down)
+	if hx != 0 && hy != 0 {
+		switch mode {
+		case HatSOCDFavorHorizontal:
+			hy = 0
+		case HatSOCDFavorVertical:
+			hx = 0
+		}
+	}
+	return hx, hy
+}
+
+// hatValue returns -1, 0 or 1 for a pair of already SOCD-resolved directions
+func hatValue(negative, positive bool) int32 {
+	switch {
+	case negative && !positive:
+		return -1
+	case positive && !negative:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package procon2
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// StuckOptions configures the detection of buttons a damaged controller reports as
+// permanently pressed
+type StuckOptions struct {
+	Threshold time.Duration // Held this long without a release counts as stuck, zero disables detection
+	Mask      bool          // Stop forwarding stuck buttons until they are released or unmasked
+}
+
+// StuckDetector warns about, and optionally masks, buttons held for too long
+type StuckDetector struct {
+	mu       sync.Mutex // Unmask can be called from another goroutine
+	opts     StuckOptions
+	name     string
+	started  bool
+	since    map[Button]time.Time
+	stuck    map[Button]bool
+	unmasked map[Button]bool // Masking revoked until the next release
+}
+
+// NewStuckDetector creates a detector, name prefixes its log lines
+func NewStuckDetector(name string, opts StuckOptions) *StuckDetector {
+	return &StuckDetector{
+		opts:     opts,
+		name:     name,
+		since:    make(map[Button]time.Time),
+		stuck:    make(map[Button]bool),
+		unmasked: make(map[Button]bool),
+	}
+}
+
+// Apply tracks the buttons of state and returns it with the masked ones released
+func (d *StuckDetector) Apply(state ControllerState, now time.Time) ControllerState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	first := !d.started
+	d.started = true
+	for _, b := range AllButtons {
+		if !state.Pressed(b) {
+			if d.stuck[b] {
+				log.Printf("✅ %s: %s was released, no longer considered stuck", d.name, b)
+			}
+			delete(d.since, b)
+			delete(d.stuck, b)
+			delete(d.unmasked, b)
+			continue
+		}
+
+		since, held := d.since[b]
+		if !held {
+			d.since[b] = now
+			if first {
+				// Nobody presses buttons while plugging a controller in
+				log.Printf("⚠️ %s: %s is already pressed at connect time, it may be stuck", d.name, b)
+			}
+			continue
+		}
+		if !d.stuck[b] && now.Sub(since) >= d.opts.Threshold {
+			d.stuck[b] = true
+			if d.opts.Mask {
+				log.Printf("⚠️ %s: %s held for %v without release, masking it until it is released", d.name, b, d.opts.Threshold)
+			} else {
+				log.Printf("⚠️ %s: %s held for %v without release, it may be stuck", d.name, b, d.opts.Threshold)
+			}
+		}
+		if d.stuck[b] && d.opts.Mask && !d.unmasked[b] {
+			state.SetPressed(b, false)
+		}
+	}
+	return state
+}
+
+// Stuck returns the buttons currently considered stuck, in AllButtons order
+func (d *StuckDetector) Stuck() []Button {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var stuck []Button
+	for _, b := range AllButtons {
+		if d.stuck[b] {
+			stuck = append(stuck, b)
+		}
+	}
+	return stuck
+}
+
+// Unmask forwards b again even though it is stuck, e.g. once the hardware is fixed.
+// It applies until b is next released.
+func (d *StuckDetector) Unmask(b Button) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.unmasked[b] = true
+}
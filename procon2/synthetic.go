@@ -0,0 +1,144 @@
+package procon2
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// syntheticReportInterval is how often a synthetic controller sends a report, like a real one
+const syntheticReportInterval = 4 * time.Millisecond
+
+// AddSynthetic starts a controller without hardware, for stress testing the manager: random
+// reports are written into a pipe read by a real HIDReader, and forwarded to real virtual
+// devices. It takes a slot like a plugged controller would, serial included, and is read-only
+// so nothing tries to initialize it. It returns the unique ID of its driver.
+func (m *Manager) AddSynthetic(serial string) (string, error) {
+	uid := fmt.Sprintf("synthetic-%d", m.synthSeq.Add(1))
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return "", fmt.Errorf("manager is shutting down")
+	}
+	var moved []*ActiveDriver
+	var virtual *VirtualGamepad
+	slot := -1
+	if h, ok := m.held[serial]; ok && serial != "" {
+		h.timer.Stop()
+		if h.virtualTimer != nil {
+			h.virtualTimer.Stop()
+		}
+		delete(m.held, serial)
+		slot, virtual = h.slot, h.virtual
+	} else {
+		slot = m.assignedSlot(serial, &moved)
+		if slot == -1 {
+			slot = m.rememberedSlot(serial, &moved)
+		}
+		if slot == -1 {
+			slot = m.findUnclaimedSlot(serial)
+		}
+	}
+	if slot == -1 {
+		m.mu.Unlock()
+		return "", fmt.Errorf("all %d player slots are full", MaxPlayers)
+	}
+	m.starting[uid] = true
+	m.pending.Add(1)
+	m.mu.Unlock()
+
+	m.showSlots(moved)
+	log.Printf("🤖 Synthetic controller %s (%s) -> Player %d", uid, serial, slot+1)
+	ad, err := m.startSynthetic(uid, serial, slot, virtual)
+	m.register(pendingStart{uid: uid, serial: serial, slot: slot, virtual: virtual}, ad, err)
+	return uid, err
+}
+
+// UnplugSynthetic stops the reports of a synthetic controller, which then goes through
+// the same teardown as a real controller that stopped answering
+func (m *Manager) UnplugSynthetic(uid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ad, ok := m.drivers[uid]
+	if !ok {
+		return fmt.Errorf("no running controller at %s", uid)
+	}
+	if ad.Driver.source == nil {
+		return fmt.Errorf("controller at %s is not synthetic", uid)
+	}
+	return ad.Driver.source.Close()
+}
+
+// startSynthetic is startDriver for a synthetic controller
+func (m *Manager) startSynthetic(uid, serial string, slotIndex int, virtual *VirtualGamepad) (*ActiveDriver, error) {
+	lg := newDriverLogger(uid, slotIndex, m.faults)
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	ctrl := &Controller{
+		hidPath: "synthetic",
+		caps:    Capabilities{Paddles: true},
+		profile: ProController2Profile,
+		logger:  lg,
+		clock:   m.clock,
+	}
+	reader := newHIDReader(r, m.calibrationFor(serial), HIDReaderOptions{Profile: ctrl.profile, Clock: m.clock, StickClamp: m.cfg.StickClamp})
+	go reader.runReadLoop(time.Time{})
+	go writeSyntheticReports(w, m.clock)
+
+	d := &Driver{controller: ctrl, reader: reader, log: lg, source: w, serial: serial}
+	if !m.cfg.DryRun {
+		if err := m.createOutputs(d, slotIndex, virtual, false); err != nil {
+			d.Close() // The writer stops once the reader closed its end
+			return nil, err
+		}
+	}
+	if virtual != nil && d.virtual != virtual {
+		virtual.Close()
+	}
+
+	return &ActiveDriver{
+		Driver:    d,
+		Slot:      slotIndex,
+		UniqueID:  uid,
+		Serial:    serial,
+		StopChan:  make(chan struct{}),
+		reslotted: make(chan struct{}, 1),
+		reinit:    make(chan struct{}, 1),
+		requested: make(chan struct{}, 1),
+		Connected: m.clock.Now(),
+	}, nil
+}
+
+// writeSyntheticReports writes random Pro Controller 2 reports to w, paced by clock, until
+// writing fails, which happens once the reader closed its end or UnplugSynthetic closed w
+func writeSyntheticReports(w *os.File, clock Clock) {
+	defer w.Close()
+	rng := rand.New(rand.NewSource(clock.Now().UnixNano()))
+	var report [64]byte
+	report[0] = 0x09
+	for counter := byte(0); ; counter++ {
+		report[1] = counter // Keeps every report fresh, like the timer byte of real ones
+		// A few buttons at a time, most reports leave them alone
+		if rng.Intn(8) == 0 {
+			for i := 3; i <= 5; i++ {
+				report[i] = byte(rng.Intn(256)) & byte(rng.Intn(256)) & byte(rng.Intn(256))
+			}
+		}
+		for _, off := range []int{6, 9} {
+			x, y := rng.Intn(4096), rng.Intn(4096)
+			report[off] = byte(x)
+			report[off+1] = byte(x>>8&0x0F) | byte(y&0x0F)<<4
+			report[off+2] = byte(y >> 4)
+		}
+		if _, err := w.Write(report[:]); err != nil {
+			return
+		}
+		clock.Sleep(syntheticReportInterval)
+	}
+}
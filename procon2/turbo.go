@@ -0,0 +1,84 @@
+package procon2
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultTurboPeriod is one full press/release cycle when a rule doesn't set its own
+const DefaultTurboPeriod = 100 * time.Millisecond
+
+// TurboRule auto-fires a button.
+// Without a modifier the target pulses while it is held. With a modifier the target
+// pulses on its own while the modifier is held, and behaves normally otherwise.
+type TurboRule struct {
+	Target   Button
+	Modifier Button // ButtonNone for always-on turbo
+	Period   time.Duration
+}
+
+// ParseTurboRules parses a comma separated list of TARGET[@MODIFIER][:PERIOD] rules,
+// e.g. "A@ZR:80ms,B" fires A while ZR is held and turbos B while it is held
+func ParseTurboRules(spec string) ([]TurboRule, error) {
+	var rules []TurboRule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		rule := TurboRule{Period: DefaultTurboPeriod}
+		if name, period, ok := strings.Cut(item, ":"); ok {
+			d, err := time.ParseDuration(period)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid turbo period in %q", item)
+			}
+			item, rule.Period = name, d
+		}
+		target, modifier, hasModifier := strings.Cut(item, "@")
+
+		var err error
+		if rule.Target, err = ParseButton(target); err != nil {
+			return nil, err
+		}
+		if hasModifier {
+			if rule.Modifier, err = ParseButton(modifier); err != nil {
+				return nil, err
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Turbo applies turbo rules to controller states
+type Turbo struct {
+	rules []TurboRule
+	start time.Time
+}
+
+// NewTurbo creates a turbo layer, all rules share the same phase origin: the first Apply
+func NewTurbo(rules []TurboRule) *Turbo {
+	return &Turbo{rules: rules}
+}
+
+// Apply returns state with the turbo rules applied at time now
+func (t *Turbo) Apply(state ControllerState, now time.Time) ControllerState {
+	if t.start.IsZero() {
+		t.start = now
+	}
+	for _, r := range t.rules {
+		// On for the first half of each period, off for the second
+		on := now.Sub(t.start)%r.Period < r.Period/2
+
+		if r.Modifier != ButtonNone {
+			if state.Pressed(r.Modifier) {
+				state.SetPressed(r.Target, on)
+			}
+		} else if state.Pressed(r.Target) {
+			state.SetPressed(r.Target, on)
+		}
+	}
+	return state
+}
@@ -0,0 +1,662 @@
+package procon2
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// --- UInput Constants ---
+const (
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetRelBit  = 0x40045566
+	uiSetAbsBit  = 0x40045567
+	uiDevSetup   = 0x405c5503
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiAbsSetup   = 0x401c5504
+
+	evSyn = 0x00
+	evKey = 0x01
+	evRel = 0x02
+	evAbs = 0x03
+
+	btnJoystick  = 0x120
+	btnSouth     = 0x130
+	btnEast      = 0x131
+	btnNorth     = 0x133
+	btnWest      = 0x134
+	btnTL        = 0x136
+	btnTR        = 0x137
+	btnTL2       = 0x138
+	btnTR2       = 0x139
+	btnSelect    = 0x13a
+	btnStart     = 0x13b
+	btnMode      = 0x13c
+	btnThumbL    = 0x13d
+	btnThumbR    = 0x13e
+	btnDpadUp    = 0x220
+	btnDpadDown  = 0x221
+	btnDpadLeft  = 0x222
+	btnDpadRight = 0x223
+
+	btnTriggerHappy1 = 0x2c0
+
+	absX     = 0x00
+	absY     = 0x01
+	absRX    = 0x03
+	absRY    = 0x04
+	absHat0X = 0x10
+	absHat0Y = 0x11
+	busUsb   = 0x03
+)
+
+// HomeMapping selects what the Home button is forwarded as
+type HomeMapping int
+
+const (
+	HomeAsMode   HomeMapping = iota // BTN_MODE, which Steam and desktop software may intercept
+	HomeAsButton                    // BTN_TRIGGER_HAPPY1, an ordinary button nothing grabs
+	HomeDisabled                    // Not forwarded at all
+)
+
+// ParseHomeMapping converts a mapping name as used on the command line
+func ParseHomeMapping(name string) (HomeMapping, error) {
+	switch name {
+	case "mode":
+		return HomeAsMode, nil
+	case "button":
+		return HomeAsButton, nil
+	case "off":
+		return HomeDisabled, nil
+	}
+	return HomeAsMode, fmt.Errorf("unknown Home mapping %q (expected mode, button or off)", name)
+}
+
+// DpadMode selects how the D-pad is forwarded
+type DpadMode int
+
+const (
+	DpadAsButtons DpadMode = iota // BTN_DPAD_UP/DOWN/LEFT/RIGHT
+	DpadAsHat                     // ABS_HAT0X/Y, as most USB gamepads report it
+)
+
+// ParseDpadMode converts a mode name as used on the command line
+func ParseDpadMode(name string) (DpadMode, error) {
+	switch name {
+	case "buttons":
+		return DpadAsButtons, nil
+	case "hat":
+		return DpadAsHat, nil
+	}
+	return DpadAsButtons, fmt.Errorf("unknown D-pad mode %q (expected buttons or hat)", name)
+}
+
+// AxisRange is the value range of a virtual stick axis
+type AxisRange struct {
+	Min, Max int32
+}
+
+// Common axis ranges
+var (
+	AxisRange16 = AxisRange{-32768, 32767} // Signed 16-bit, the default
+	AxisRange8  = AxisRange{0, 255}        // Unsigned 8-bit, as older controllers report
+)
+
+// orDefault returns r, or AxisRange16 for the zero value
+func (r AxisRange) orDefault() AxisRange {
+	if r == (AxisRange{}) {
+		return AxisRange16
+	}
+	return r
+}
+
+// center is the value reported for a centered stick
+func (r AxisRange) center() int32 {
+	return int32((int64(r.Min) + int64(r.Max) + 1) / 2)
+}
+
+// scale maps a normalized value (-1 to 1) to the range, each half of the stick
+// covering its side of the center
+func (r AxisRange) scale(v float64) int32 {
+	c := r.center()
+	if v >= 0 {
+		return c + int32(math.Round(v*float64(r.Max-c)))
+	}
+	return c + int32(math.Round(v*float64(c-r.Min)))
+}
+
+// absinfo is the uinput setup of an axis with this range
+func (r AxisRange) absinfo() inputAbsinfo {
+	return inputAbsinfo{min: r.Min, max: r.Max, fuzz: r.scaledFromFull(16), flat: r.scaledFromFull(128)}
+}
+
+// scaledFromFull converts a quantity expressed for AxisRange16, e.g. fuzz, to this range
+func (r AxisRange) scaledFromFull(n int32) int32 {
+	return int32(int64(n) * (int64(r.Max) - int64(r.Min)) / 65535)
+}
+
+func (r AxisRange) String() string {
+	return fmt.Sprintf("%d:%d", r.Min, r.Max)
+}
+
+// AxisRanges sets the range of each stick axis, zero values use AxisRange16
+type AxisRanges struct {
+	LX, LY, RX, RY AxisRange
+}
+
+// ParseAxisRanges parses a comma separated list of [AXIS=]MIN:MAX, e.g. "0:255" for every
+// axis or "lx=0:255,ly=0:255". AXIS is one of lx, ly, rx and ry.
+func ParseAxisRanges(spec string) (AxisRanges, error) {
+	var ranges AxisRanges
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		axis, bounds, named := strings.Cut(item, "=")
+		if !named {
+			axis, bounds = "", item
+		}
+		lo, hi, ok := strings.Cut(bounds, ":")
+		minV, errMin := strconv.ParseInt(lo, 10, 32)
+		maxV, errMax := strconv.ParseInt(hi, 10, 32)
+		if !ok || errMin != nil || errMax != nil || minV >= maxV {
+			return AxisRanges{}, fmt.Errorf("invalid axis range %q (expected [AXIS=]MIN:MAX)", item)
+		}
+		r := AxisRange{int32(minV), int32(maxV)}
+		switch strings.ToLower(axis) {
+		case "":
+			ranges = AxisRanges{r, r, r, r}
+		case "lx":
+			ranges.LX = r
+		case "ly":
+			ranges.LY = r
+		case "rx":
+			ranges.RX = r
+		case "ry":
+			ranges.RY = r
+		default:
+			return AxisRanges{}, fmt.Errorf("unknown axis %q in %q (expected lx, ly, rx or ry)", axis, item)
+		}
+	}
+	return ranges, nil
+}
+
+// GamepadOptions configures how a VirtualGamepad presents itself
+type GamepadOptions struct {
+	Home HomeMapping
+	Dpad DpadMode
+	// HatSOCD resolves the D-pad into a single hat value when Dpad is DpadAsHat
+	HatSOCD HatSOCDMode
+	Axes    AxisRanges
+	// Deadzone is the normalized stick deflection ignored around the center, per axis,
+	// on top of the raw calibration deadzone. Zero uses DefaultGamepadOptions.Deadzone,
+	// NoDeadzone turns it off.
+	Deadzone float64
+	// LeftCurve and RightCurve reshape each stick's response after Deadzone, nil keeps it linear
+	LeftCurve, RightCurve ResponseCurve
+	// YUp forwards a stick pushed up as positive Y. Off, it is negative like on most gamepads.
+	YUp bool
+	// Identity is the name and USB IDs the gamepad presents, IdentityProController when unset
+	Identity VirtualIdentity
+	// NoNeutralFrame skips the centered frame sent right after creation, leaving
+	// the device silent until the first controller report
+	NoNeutralFrame bool
+	// Bindings adds codes sent along with buttons, only the gamepad ones are used
+	Bindings Bindings
+}
+
+// NoDeadzone as GamepadOptions.Deadzone forwards the sticks without a normalized deadzone
+const NoDeadzone = -1.0
+
+// DefaultGamepadOptions mimics a standard gamepad
+var DefaultGamepadOptions = GamepadOptions{
+	Home:     HomeAsMode,
+	Dpad:     DpadAsButtons,
+	Deadzone: 0.05,
+}
+
+// VirtualGamepad is a uinput gamepad that mirrors a controller's state
+type VirtualGamepad struct {
+	mu        sync.Mutex // Serializes Update against Close
+	file      *os.File
+	lastState ControllerState
+	deadzone  float64
+	homeCode  uint16 // 0 when Home is not forwarded
+	socd      SOCDMode
+	socdV     socdAxis // Up / Down
+	socdH     socdAxis // Left / Right
+	hat       bool     // D-pad forwarded as ABS_HAT0X/Y
+	hatSOCD   HatSOCDMode
+	curves    [2]ResponseCurve // Left and right stick
+	ySign     float64          // -1 forwards up as negative Y, see GamepadOptions.YUp
+	ranges    [4]AxisRange     // LX, LY, RX, RY
+	pending   []inputEvent
+	relay     bool // Writing to a device someone else created, see NewVirtualGamepadFromFile
+	extra     extraCodes
+	held      map[uint16]bool // Extra codes of the frame being built, see sendButton
+	deviceClock
+}
+
+// NewVirtualGamepad creates a new virtual gamepad with Player Number in name
+func NewVirtualGamepad(playerNum int, opts GamepadOptions) (*VirtualGamepad, error) {
+	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /dev/uinput: %w", err)
+	}
+
+	// Basic Setup (Keys, Axes, etc) - Same as original
+	ioctl(f.Fd(), uiSetEvBit, uintptr(evKey))
+	ioctl(f.Fd(), uiSetEvBit, uintptr(evAbs))
+	ioctl(f.Fd(), uiSetEvBit, uintptr(evSyn))
+
+	hat := opts.Dpad == DpadAsHat
+	homeCode := opts.homeCode()
+	extra := opts.Bindings.codes(OutputGamepad)
+	for _, btn := range opts.buttonCodes() {
+		ioctl(f.Fd(), uiSetKeyBit, uintptr(btn))
+	}
+
+	axes := []uint16{absX, absY, absRX, absRY}
+	for _, ax := range axes {
+		ioctl(f.Fd(), uiSetAbsBit, uintptr(ax))
+	}
+	if hat {
+		ioctl(f.Fd(), uiSetAbsBit, uintptr(absHat0X))
+		ioctl(f.Fd(), uiSetAbsBit, uintptr(absHat0Y))
+	}
+
+	// Device Setup with Naming
+	var usetup uinputSetup
+	id := opts.identity()
+	name := fmt.Sprintf("%s (Player %d)", id.Name, playerNum)
+	copy(usetup.name[:], name)
+	usetup.id.bustype = busUsb
+	usetup.id.vendor = id.Vendor
+	usetup.id.product = id.Product
+	usetup.id.version = id.Version
+
+	if err := ioctlSetup(f.Fd(), uiDevSetup, unsafe.Pointer(&usetup)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("UI_DEV_SETUP failed: %w", err)
+	}
+
+	// Axis Setup, a failed axis falls back to the default range so scaling still matches it
+	ranges := opts.axisRanges()
+	for i, ax := range axes {
+		err := setupAbs(f, ax, ranges[i].absinfo())
+		if err == nil {
+			continue
+		}
+		if ranges[i] != AxisRange16 && setupAbs(f, ax, AxisRange16.absinfo()) == nil {
+			log.Printf("⚠️ Setting up %s as %v failed (%v), using %v instead", absName(ax), ranges[i], err, AxisRange16)
+			ranges[i] = AxisRange16
+			continue
+		}
+		log.Printf("⚠️ Setting up %s failed (%v), games may see it stuck", absName(ax), err)
+	}
+	if hat {
+		for _, ax := range []uint16{absHat0X, absHat0Y} {
+			if err := setupAbs(f, ax, inputAbsinfo{min: -1, max: 1}); err != nil {
+				log.Printf("⚠️ Setting up %s failed (%v), games may see it stuck", absName(ax), err)
+			}
+		}
+	}
+
+	if err := ioctl(f.Fd(), uiDevCreate, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("UI_DEV_CREATE failed: %w", err)
+	}
+	// f.Fd() switched f to blocking mode, writes handle EAGAIN themselves
+	syscall.SetNonblock(int(f.Fd()), true)
+
+	v := &VirtualGamepad{
+		file:     f,
+		deadzone: opts.deadzone(),
+		homeCode: homeCode,
+		hat:      hat,
+		hatSOCD:  opts.HatSOCD,
+		curves:   [2]ResponseCurve{opts.LeftCurve, opts.RightCurve},
+		ySign:    opts.ySign(),
+		ranges:   ranges,
+		extra:    extra,
+	}
+	if !opts.NoNeutralFrame {
+		// Some games latch the first values they read, give them a well-defined centered state
+		v.update(ControllerState{})
+	}
+	return v, nil
+}
+
+// NewVirtualGamepadFromFile writes to a uinput device another process or library created
+// and owns, e.g. to chain with other tools. f must be its uinput file, after UI_DEV_CREATE,
+// with the capabilities opts implies. Close leaves both the device and f alone.
+func NewVirtualGamepadFromFile(f *os.File, opts GamepadOptions) (*VirtualGamepad, error) {
+	if f == nil {
+		return nil, fmt.Errorf("no uinput file")
+	}
+	v := &VirtualGamepad{
+		file:     f,
+		deadzone: opts.deadzone(),
+		homeCode: opts.homeCode(),
+		hat:      opts.Dpad == DpadAsHat,
+		hatSOCD:  opts.HatSOCD,
+		curves:   [2]ResponseCurve{opts.LeftCurve, opts.RightCurve},
+		ySign:    opts.ySign(),
+		ranges:   opts.axisRanges(),
+		relay:    true,
+		extra:    opts.Bindings.codes(OutputGamepad),
+	}
+	if !opts.NoNeutralFrame {
+		if err := v.update(ControllerState{}); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// homeCode is the key Home is forwarded as, 0 when it isn't
+func (o GamepadOptions) homeCode() uint16 {
+	switch o.Home {
+	case HomeAsMode:
+		return btnMode
+	case HomeAsButton:
+		return btnTriggerHappy1
+	}
+	return 0
+}
+
+// buttonCodes returns every key code the gamepad advertises
+func (o GamepadOptions) buttonCodes() []uint16 {
+	buttons := []uint16{
+		btnSouth, btnEast, btnNorth, btnWest,
+		btnTL, btnTR, btnTL2, btnTR2,
+		btnSelect, btnStart,
+		btnThumbL, btnThumbR,
+	}
+	if o.Dpad != DpadAsHat {
+		buttons = append(buttons, btnDpadUp, btnDpadDown, btnDpadLeft, btnDpadRight)
+	}
+	if code := o.homeCode(); code != 0 {
+		buttons = append(buttons, code)
+	}
+	return append(buttons, o.Bindings.codes(OutputGamepad).all()...)
+}
+
+// ySign is what normalized Y values, positive when up, are multiplied by
+func (o GamepadOptions) ySign() float64 {
+	if o.YUp {
+		return 1
+	}
+	return -1
+}
+
+// identity returns Identity, IdentityProController when unset
+func (o GamepadOptions) identity() VirtualIdentity {
+	if o.Identity == (VirtualIdentity{}) {
+		return IdentityProController
+	}
+	return o.Identity
+}
+
+// deadzone returns the normalized deadzone in effect, see GamepadOptions.Deadzone
+func (o GamepadOptions) deadzone() float64 {
+	switch {
+	case o.Deadzone == 0:
+		return DefaultGamepadOptions.Deadzone
+	case o.Deadzone < 0:
+		return 0
+	}
+	return o.Deadzone
+}
+
+// axisRanges returns the LX, LY, RX and RY ranges, defaults filled in
+func (o GamepadOptions) axisRanges() [4]AxisRange {
+	return [4]AxisRange{o.Axes.LX.orDefault(), o.Axes.LY.orDefault(), o.Axes.RX.orDefault(), o.Axes.RY.orDefault()}
+}
+
+// Update forwards a controller state to the virtual device
+func (v *VirtualGamepad) Update(state ControllerState) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.file == nil {
+		return fmt.Errorf("virtual gamepad closed")
+	}
+	return v.update(state)
+}
+
+// Reset releases every button and centers every axis in a single frame
+func (v *VirtualGamepad) Reset() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.file == nil {
+		return fmt.Errorf("virtual gamepad closed")
+	}
+	return v.reset()
+}
+
+func (v *VirtualGamepad) reset() error {
+	v.socdV, v.socdH = socdAxis{}, socdAxis{}
+	return v.update(ControllerState{})
+}
+
+// SetSOCDMode changes how opposing D-pad presses are forwarded as buttons
+func (v *VirtualGamepad) SetSOCDMode(mode SOCDMode) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.socd = mode
+}
+
+func (v *VirtualGamepad) update(state ControllerState) error {
+	v.held = v.extra.pressed(state, v.held)
+	v.sendButton(btnSouth, state.A)
+	v.sendButton(btnEast, state.B)
+	v.sendButton(btnNorth, state.X)
+	v.sendButton(btnWest, state.Y)
+	v.sendButton(btnTL, state.L)
+	v.sendButton(btnTR, state.R)
+	v.sendButton(btnTL2, state.ZL)
+	v.sendButton(btnTR2, state.ZR)
+	if v.hat {
+		// Both hat axes go out in the same frame, so a diagonal never shows up half applied
+		x, y := resolveHat(v.hatSOCD, &v.socdH, &v.socdV, state)
+		v.sendAxis(absHat0X, x)
+		v.sendAxis(absHat0Y, y)
+	} else {
+		up, down := v.socdV.resolve(v.socd, state.DpadUp, state.DpadDown, true)
+		left, right := v.socdH.resolve(v.socd, state.DpadLeft, state.DpadRight, false)
+		v.sendButton(btnDpadUp, up)
+		v.sendButton(btnDpadDown, down)
+		v.sendButton(btnDpadLeft, left)
+		v.sendButton(btnDpadRight, right)
+	}
+	v.sendButton(btnStart, state.Plus)
+	v.sendButton(btnSelect, state.Minus)
+	if v.homeCode != 0 {
+		v.sendButton(v.homeCode, state.Home)
+	}
+	v.sendButton(btnThumbL, state.LStickPress)
+	v.sendButton(btnThumbR, state.RStickPress)
+	// Extra codes no button above already sent
+	for code, pressed := range v.held {
+		v.sendButton(code, pressed)
+	}
+
+	lx := v.applyDeadzone(state.Joysticks.LX)
+	ly := v.applyDeadzone(v.ySign * state.Joysticks.LY)
+	rx := v.applyDeadzone(state.Joysticks.RX)
+	ry := v.applyDeadzone(v.ySign * state.Joysticks.RY)
+	lx, ly = v.curves[0].applyStick(lx, ly)
+	rx, ry = v.curves[1].applyStick(rx, ry)
+
+	v.sendAxis(absX, v.ranges[0].scale(lx))
+	v.sendAxis(absY, v.ranges[1].scale(ly))
+	v.sendAxis(absRX, v.ranges[2].scale(rx))
+	v.sendAxis(absRY, v.ranges[3].scale(ry))
+
+	if err := v.sendSync(); err != nil {
+		return err
+	}
+	v.lastState = state
+	return nil
+}
+
+// sendButton queues a key event. A code also bound to a held button through
+// GamepadOptions.Bindings stays pressed, and is only sent once per frame.
+func (v *VirtualGamepad) sendButton(code uint16, pressed bool) {
+	if extra, ok := v.held[code]; ok {
+		pressed = pressed || extra
+		delete(v.held, code)
+	}
+	val := int32(0)
+	if pressed {
+		val = 1
+	}
+	v.writeEvent(evKey, code, val)
+}
+func (v *VirtualGamepad) sendAxis(code uint16, value int32) {
+	v.writeEvent(evAbs, code, value)
+}
+
+// sendSync ends the frame and writes all its events at once, so readers never
+// see part of a frame
+func (v *VirtualGamepad) sendSync() error {
+	v.writeEvent(evSyn, 0, 0)
+	err := writeInputEvents(v.file, v.pending, v.clock)
+	v.pending = v.pending[:0]
+	return err
+}
+func (v *VirtualGamepad) writeEvent(typ, code uint16, value int32) {
+	v.pending = append(v.pending, inputEvent{typ: typ, code: code, value: value})
+}
+
+func (v *VirtualGamepad) applyDeadzone(value float64) float64 {
+	return applyDeadzone(value, v.deadzone)
+}
+
+// applyDeadzone zeroes a normalized axis value within deadzone of the center
+func applyDeadzone(value, deadzone float64) float64 {
+	if value > -deadzone && value < deadzone {
+		return 0.0
+	}
+	return value
+}
+
+// ApplyDeadzone returns the stick values a virtual gamepad with this normalized
+// deadzone forwards, see GamepadOptions.Deadzone
+func (j JoystickValues) ApplyDeadzone(deadzone float64) JoystickValues {
+	return JoystickValues{
+		LX: applyDeadzone(j.LX, deadzone),
+		LY: applyDeadzone(j.LY, deadzone),
+		RX: applyDeadzone(j.RX, deadzone),
+		RY: applyDeadzone(j.RY, deadzone),
+	}
+}
+func (v *VirtualGamepad) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.file != nil {
+		// Release every button and center the sticks before the device goes away,
+		// otherwise games can keep the last forwarded input latched
+		v.reset()
+
+		if v.relay {
+			// The device and its file belong to whoever created them
+			v.file = nil
+			return nil
+		}
+		ioctl(v.file.Fd(), uiDevDestroy, 0)
+		err := v.file.Close()
+		v.file = nil
+		return err
+	}
+	return nil
+}
+
+// UInput Structs
+type inputEvent struct {
+	time      syscall.Timeval
+	typ, code uint16
+	value     int32
+}
+type inputId struct {
+	bustype, vendor, product, version uint16
+}
+type inputAbsinfo struct {
+	value, min, max, fuzz, flat, resolution int32
+}
+type uinputAbsSetup struct {
+	code uint16
+	_    [2]byte
+	info inputAbsinfo
+	_    [4]byte
+}
+type uinputSetup struct {
+	id           inputId
+	name         [80]byte
+	ffEffectsMax uint32
+	absinfo      [0x40]uinputAbsSetup
+}
+
+func ioctl(fd uintptr, request uintptr, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setupAbs sets up an axis with UI_ABS_SETUP, trying once more when it fails
+func setupAbs(f *os.File, code uint16, info inputAbsinfo) error {
+	setup := uinputAbsSetup{code: code, info: info}
+	err := ioctlSetup(f.Fd(), uiAbsSetup, unsafe.Pointer(&setup))
+	if err != nil {
+		err = ioctlSetup(f.Fd(), uiAbsSetup, unsafe.Pointer(&setup))
+	}
+	if err != nil {
+		return fmt.Errorf("UI_ABS_SETUP: %w", err)
+	}
+	return nil
+}
+
+// absName names an axis code in log lines
+func absName(code uint16) string {
+	switch code {
+	case absX:
+		return "ABS_X"
+	case absY:
+		return "ABS_Y"
+	case absZ:
+		return "ABS_Z"
+	case absRX:
+		return "ABS_RX"
+	case absRY:
+		return "ABS_RY"
+	case absRZ:
+		return "ABS_RZ"
+	case absHat0X:
+		return "ABS_HAT0X"
+	case absHat0Y:
+		return "ABS_HAT0Y"
+	}
+	return fmt.Sprintf("axis 0x%02x", code)
+}
+
+func ioctlSetup(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package procon2
+
+import "testing"
+
+func TestGamepadOptionsDeadzone(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadzone float64
+		want     float64
+	}{
+		{"unset", 0, DefaultGamepadOptions.Deadzone},
+		{"no deadzone", NoDeadzone, 0},
+		{"any negative", -0.3, 0},
+		{"set", 0.12, 0.12},
+	}
+	for _, tt := range tests {
+		if got := (GamepadOptions{Deadzone: tt.deadzone}).deadzone(); got != tt.want {
+			t.Errorf("%s: deadzone() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Presets go through the same rules, the default one keeps the default deadzone
+	cfg := DefaultConfig
+	JoystickPresets["default"].Apply(&cfg)
+	if got := cfg.Gamepad.deadzone(); got != DefaultGamepadOptions.Deadzone {
+		t.Errorf("default preset: deadzone() = %v, want %v", got, DefaultGamepadOptions.Deadzone)
+	}
+}
@@ -0,0 +1,59 @@
+package procon2
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends a state line to systemd, doing nothing outside of a systemd service
+func sdNotify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// watchdogInterval returns the systemd watchdog timeout, 0 when WatchdogSec isn't set
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// runWatchdog pings the systemd watchdog as long as scans keep completing, so a wedged
+// scanner gets the service restarted. It returns at once when no watchdog is configured.
+func (m *Manager) runWatchdog(ctx context.Context) {
+	interval := watchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := m.clock.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.Chan():
+			if m.ScanHealthy(interval) {
+				if err := sdNotify("WATCHDOG=1"); err != nil {
+					m.logDedup("⚠️ Could not ping the systemd watchdog: %v", err)
+				}
+			} else {
+				m.logDedup("⚠️ No scan completed for %v, letting the watchdog expire", m.clock.Now().Sub(m.LastScan()).Round(time.Second))
+			}
+		}
+	}
+}
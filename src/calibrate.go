@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dalmatheo/procon2-driver/procon2"
+	"github.com/google/gousb"
+)
+
+// Exit codes of -calibrate-auto
+const (
+	exitCalibrated     = 0
+	exitNoController   = 1
+	exitCalibrateError = 2
+	exitSaveError      = 3
+)
+
+// session is a controller the calibration modes work with
+type session struct {
+	ctx    *gousb.Context // Only closed by sessions that own it
+	dev    *gousb.Device
+	ctrl   *procon2.Controller
+	reader *procon2.HIDReader
+	serial string
+}
+
+// traceUSB is set by -trace-usb, sessions then log every USB transfer
+var traceUSB bool
+
+// openSession opens the first connected controller and starts reading it
+func openSession(match func(*gousb.DeviceDesc) bool, iface procon2.USBInterface, initOpts procon2.InitOptions, initDelay time.Duration) (*session, error) {
+	ctx := gousb.NewContext()
+
+	// Find first Pro Controller
+	devs, err := ctx.OpenDevices(match)
+	if err != nil || len(devs) == 0 {
+		ctx.Close()
+		return nil, fmt.Errorf("No Pro Controller found. Please connect one.")
+	}
+
+	// Close other devices
+	for i := 1; i < len(devs); i++ {
+		devs[i].Close()
+	}
+
+	s, err := startSession(devs[0], iface, initOpts, initDelay)
+	if err != nil {
+		ctx.Close()
+		return nil, err
+	}
+	s.ctx = ctx
+	return s, nil
+}
+
+// startSession initializes an open device and starts reading it. The device is closed on error.
+func startSession(dev *gousb.Device, iface procon2.USBInterface, initOpts procon2.InitOptions, initDelay time.Duration) (*session, error) {
+	s := &session{dev: dev}
+	s.serial, _ = s.dev.SerialNumber()
+
+	// Initialize controller
+	if iface.Config == 0 {
+		iface = procon2.USBInterfaceFor(s.dev.Desc)
+	}
+	var err error
+	if s.ctrl, err = procon2.NewController(s.dev, iface.Config, iface.Interface); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("Failed to initialize controller: %w", err)
+	}
+	s.ctrl.SetUSBTrace(traceUSB)
+
+	if !s.ctrl.ReadOnly() {
+		if err := s.ctrl.SendInitSequenceWithOptions(initOpts); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("Failed to send init sequence: %w", err)
+		}
+
+		time.Sleep(initDelay)
+	}
+
+	if s.ctrl.GetHIDPath() == "" {
+		s.Close()
+		return nil, fmt.Errorf("Could not find HID path for controller")
+	}
+
+	// Open reader with default calibration first
+	opts := procon2.DefaultHIDReaderOptions
+	opts.Profile = s.ctrl.Profile()
+	if s.reader, err = procon2.NewHIDReaderWithOptions(s.ctrl.GetHIDPath(), procon2.DefaultCalibration, opts); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("Failed to open HID reader: %w", err)
+	}
+	return s, nil
+}
+
+func (s *session) Close() {
+	if s.reader != nil {
+		s.reader.Close()
+	}
+	if s.ctrl != nil {
+		s.ctrl.Close()
+	}
+	if s.dev != nil {
+		s.dev.Close()
+	}
+	if s.ctx != nil {
+		s.ctx.Close()
+	}
+}
+
+// runAutoCalibration calibrates the connected controller and saves it for its serial,
+// logging key=value lines only. It returns the process exit code.
+func runAutoCalibration(match func(*gousb.DeviceDesc) bool, iface procon2.USBInterface, initOpts procon2.InitOptions, initDelay time.Duration, dir string, opts procon2.QuickCalibrateOptions) int {
+	out := log.New(os.Stdout, "", log.LstdFlags)
+	// Silence the human oriented logs of the driver
+	log.SetOutput(io.Discard)
+
+	out.Printf("calibrate step=start center=%v range=%v", opts.Center, opts.Range)
+	sess, err := openSession(match, iface, initOpts, initDelay)
+	if err != nil {
+		out.Printf("calibrate step=open status=error error=%q", err)
+		return exitNoController
+	}
+	defer sess.Close()
+	if sess.serial == "" {
+		out.Printf("calibrate step=open status=error error=%q", "controller has no serial number")
+		return exitNoController
+	}
+	out.Printf("calibrate step=open status=ok serial=%s", sess.serial)
+
+	return calibrateAndSave(out, sess, dir, opts)
+}
+
+// runCalibrateAll calibrates every connected controller at once and saves each for its
+// serial, logging key=value lines only. A controller failing doesn't stop the others.
+// It returns the worst exit code of all controllers.
+func runCalibrateAll(match func(*gousb.DeviceDesc) bool, iface procon2.USBInterface, initOpts procon2.InitOptions, initDelay time.Duration, dir string, opts procon2.QuickCalibrateOptions) int {
+	out := log.New(os.Stdout, "", log.LstdFlags)
+	// Silence the human oriented logs of the driver
+	log.SetOutput(io.Discard)
+
+	ctx := gousb.NewContext()
+	defer ctx.Close()
+
+	devs, _ := ctx.OpenDevices(match)
+	if len(devs) == 0 {
+		out.Printf("calibrate step=open status=error error=%q", "no controller found")
+		return exitNoController
+	}
+
+	// Bring every controller up first, so the measuring phases line up
+	sessions := make([]*session, len(devs))
+	var wg sync.WaitGroup
+	for i, dev := range devs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			uid := fmt.Sprintf("%d-%d", dev.Desc.Bus, dev.Desc.Address)
+			s, err := startSession(dev, iface, initOpts, initDelay)
+			switch {
+			case err != nil:
+				out.Printf("calibrate step=open status=error uid=%s error=%q", uid, err)
+			case s.serial == "":
+				out.Printf("calibrate step=open status=error uid=%s error=%q", uid, "controller has no serial number")
+				s.Close()
+			default:
+				out.Printf("calibrate step=open status=ok uid=%s serial=%s", uid, s.serial)
+				sessions[i] = s
+			}
+		}()
+	}
+	wg.Wait()
+
+	worst := exitCalibrated
+	var ready []*session
+	for _, s := range sessions {
+		if s == nil {
+			worst = exitNoController
+			continue
+		}
+		defer s.Close()
+		ready = append(ready, s)
+	}
+	if len(ready) == 0 {
+		return exitNoController
+	}
+
+	out.Printf("calibrate step=start controllers=%d center=%v range=%v", len(ready), opts.Center, opts.Range)
+	progress := time.AfterFunc(opts.Center, func() {
+		out.Printf("calibrate step=progress phase=range controllers=%d", len(ready))
+	})
+	defer progress.Stop()
+
+	codes := make([]int, len(ready))
+	for i, s := range ready {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = calibrateAndSave(out, s, dir, opts)
+		}()
+	}
+	wg.Wait()
+
+	calibrated := 0
+	for _, code := range codes {
+		if code == exitCalibrated {
+			calibrated++
+		}
+		worst = max(worst, code)
+	}
+	out.Printf("calibrate step=done calibrated=%d failed=%d", calibrated, len(devs)-calibrated)
+	return worst
+}
+
+// calibrateAndSave measures one controller and writes its calibration file
+func calibrateAndSave(out *log.Logger, s *session, dir string, opts procon2.QuickCalibrateOptions) int {
+	cal, err := procon2.QuickCalibrateWithOptions(s.reader, opts)
+	if err != nil {
+		out.Printf("calibrate step=measure status=error serial=%s error=%q", s.serial, err)
+		return exitCalibrateError
+	}
+	out.Printf("calibrate step=measure status=ok serial=%s left=%d,%d right=%d,%d deadzone=%d,%d",
+		s.serial, cal.LXCenter, cal.LYCenter, cal.RXCenter, cal.RYCenter, cal.LDeadzone, cal.RDeadzone)
+	for _, off := range procon2.CheckCalibrationCenter(cal, opts.Tuning.CenterTolerance) {
+		out.Printf("calibrate step=check status=warning serial=%s warning=%q", s.serial, off)
+	}
+
+	path := procon2.CalibrationPath(dir, s.serial)
+	if err := procon2.SaveCalibration(path, cal); err != nil {
+		out.Printf("calibrate step=save status=error serial=%s error=%q", s.serial, err)
+		return exitSaveError
+	}
+	out.Printf("calibrate step=save status=ok serial=%s path=%s", s.serial, path)
+	return exitCalibrated
+}
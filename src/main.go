@@ -1,343 +1,210 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"sync"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
-	"unsafe"
 
+	"github.com/dalmatheo/procon2-driver/procon2"
 	"github.com/google/gousb"
 )
 
-const (
-	MaxPlayers    = 4
-	DRIVER_NAME   = "Nintendo Pro Controller 2"
-	PROCON_VENDOR = 0x057e
-	EVIOCGRAB     = 0x40044590
-)
-
-// ActiveDriver represents a running controller instance
-type ActiveDriver struct {
-	Driver    *Driver
-	USBDevice *gousb.Device
-	Slot      int    // 0 to 3 (Player 1-4)
-	UniqueID  string // "Bus-Addr"
-	StopChan  chan struct{}
-	WG        sync.WaitGroup
-	GrabFile  *os.File // Handle to the grabbed evdev node
-}
-
-// Manager handles detection and lifecycle of controllers
-type Manager struct {
-	ctx     *gousb.Context
-	drivers map[string]*ActiveDriver
-	slots   [MaxPlayers]bool
-	mu      sync.Mutex
-}
+func main() {
+	daemonMode := flag.Bool("daemon", false, "Run as daemon (stderr log)")
+	dryRun := flag.Bool("dry-run", false, "Read and log controller input without creating virtual devices")
+	calibrateMode := flag.Bool("calibrate", false, "Run calibration mode")
+	healthcheck := flag.Bool("healthcheck", false, "Exit 0 if the running driver is alive, 1 otherwise (for Docker/k8s health checks)")
+	healthMaxAge := flag.Duration("healthcheck-max-age", 10*time.Second, "Oldest heartbeat -healthcheck accepts")
+	heartbeatFile := flag.String("heartbeat-file", procon2.DefaultHeartbeatFile, "File the driver rewrites after every scan (empty to disable)")
+	buttonTest := flag.Bool("buttontest", false, "Press each button in turn and print where it shows up in the reports")
+	calibrateAuto := flag.Bool("calibrate-auto", false, "Calibrate the connected controller without prompts, save it to -calibration-dir and exit")
+	calibrateAll := flag.Bool("calibrate-all", false, "Like -calibrate-auto, for every connected controller at once")
+	calibrateCenter := flag.Duration("calibrate-center", procon2.DefaultQuickCalibrateOptions.Center, "How long -calibrate-auto measures the centered sticks")
+	calibrateRange := flag.Duration("calibrate-range", procon2.DefaultQuickCalibrateOptions.Range, "How long -calibrate-auto measures the sticks' range")
+	calibrationDir := flag.String("calibration-dir", "/etc/procon2-driver/calibration", "Directory of per-serial calibration files")
+	matchClass := flag.Bool("match-class", false, "Accept any Nintendo HID gamepad, not only the known product IDs")
+	usbInterface := flag.String("usb-interface", "", "USB CONFIG:INTERFACE to claim (e.g. 1:1), picked per product when empty")
+	initFailRatio := flag.Float64("init-fail-ratio", procon2.DefaultConfig.InitFailRatio, "Fraction of init packets allowed to fail (0.0 - 1.0)")
+	initDelay := flag.Duration("init-delay", procon2.DefaultConfig.InitDelay, "Delay after the init sequence before talking to the controller")
+	initPacketDelay := flag.Duration("init-packet-delay", 0, "Pause between init packets, for clones that drop packets sent too fast (0 keeps the model's, 15ms)")
+	initVerify := flag.Bool("init-verify", false, "Wait for a reply after every init packet, resending it once when none comes")
+	flag.BoolVar(&traceUSB, "trace-usb", false, "Log every USB packet written to and read from the controllers, in hex")
+	reconnectGrace := flag.Duration("reconnect-grace", 0, "Keep the virtual gamepad this long after a disconnect (0 to disable)")
+	slotHold := flag.Duration("slot-hold", 0, "Reserve a disconnected controller's player slot this long (0 to free it immediately)")
+	noRumble := flag.Bool("no-rumble", false, "Never make controllers rumble")
+	stallReinit := flag.Bool("stall-reinit", procon2.DefaultConfig.StallReinit, "Re-run the init sequence of a stalled controller before dropping it")
+	stallTimeout := flag.Duration("stall-timeout", 0, "Drop a controller whose reports stop changing for this long (0 to disable)")
+	idleTimeout := flag.Duration("idle-timeout", 0, "Drop a controller left untouched this long, until it is plugged back in (0 to disable)")
+	playerLEDs := flag.String("player-leds", "", "LED pattern of each player as 0/1 digits, comma separated (e.g. 1000,0100,0010,0001,1100)")
+	antiDrift := flag.Bool("anti-drift", false, "Slowly recenter sticks while they rest, for worn sticks")
+	antiDriftMax := flag.Int("anti-drift-max", procon2.DefaultDriftOptions.MaxCorrection, "Largest center shift -anti-drift may apply, in raw units")
+	socdMode := flag.String("socd", "off", "Opposing D-pad presses with -dpad buttons: off, neutral, last-wins or up-priority")
+	turboSpec := flag.String("turbo", "", "Turbo rules as TARGET[@MODIFIER][:PERIOD], comma separated (e.g. A@ZR:80ms,B)")
+	flickSpec := flag.String("flick", "", "Flick rules as STICK:DIRECTION=BUTTON, comma separated (e.g. L:RIGHT=R)")
+	flickThreshold := flag.Float64("flick-threshold", procon2.DefaultFlickOptions.Threshold, "Stick velocity triggering a flick (full deflections per second)")
+	flickCooldown := flag.Duration("flick-cooldown", procon2.DefaultFlickOptions.Cooldown, "Minimum time between two flicks")
+	flickOnly := flag.Bool("flick-only", false, "Don't forward analog movement of sticks that have flick rules")
+	dpadMode := flag.String("dpad", "buttons", "Forward the D-pad as: buttons or hat (ABS_HAT0X/Y)")
+	stickDeadzone := flag.Float64("stick-deadzone", procon2.DefaultGamepadOptions.Deadzone, "Normalized stick deflection (0.0 - 1.0) the virtual gamepad ignores around the center, on top of the calibration deadzone, 0 for none")
+	curveSpec := flag.String("curve", "", "Stick response curve for both sticks, as IN:OUT points from 0 to 1 (e.g. 0:0,0.5:0.2,1:1) or @FILE with one point per line")
+	leftCurveSpec := flag.String("left-curve", "", "Like -curve, for the left stick only")
+	rightCurveSpec := flag.String("right-curve", "", "Like -curve, for the right stick only")
+	hatSOCDMode := flag.String("hat-socd", "neutral", "Opposing or diagonal D-pad presses with -dpad hat: neutral, last-input, favor-horizontal or favor-vertical")
+	homeMapping := flag.String("home", "mode", "Forward Home as: mode (BTN_MODE), button (an ordinary button) or off")
+	outputSpec := flag.String("outputs", "gamepad", "Backends to forward to: gamepad, keyboard, mouse and/or motion, comma separated. SERIAL=OUTPUT+OUTPUT sets those of one controller")
+	mouseSpeed := flag.Float64("mouse-speed", procon2.DefaultMouseOptions.Speed, "Mouse output pointer pixels per report at full stick deflection")
+	mouseInvertY := flag.Bool("mouse-invert-y", false, "Mouse output: move the pointer down when the right stick is pushed up")
+	naturalScroll := flag.Bool("natural-scroll", false, "Mouse output: scroll down when the left stick is pushed up")
+	release := flag.String("release", "", "Hand the controller with this serial or BUS-ADDR ID back to the kernel driver and exit, once the driver stopped")
+	hidrawPath := flag.String("hidraw", "", "Read the controller from this hidraw node instead of looking it up in sysfs, driving a single controller (e.g. /dev/hidraw3)")
+	evdevPath := flag.String("evdev", "", "With -hidraw, the evdev node of the controller to grab (e.g. /dev/input/event7)")
+	stickClampName := flag.String("stick-clamp", "square", "Bound calibrated stick values: square (each axis within -1 to 1), circle (magnitude within 1) or none")
+	presetName := flag.String("preset", "", "Stick preset bundling deadzone, curve and clamp: default, aggressive-aim, smooth or retro-digital. -stick-deadzone, -curve, -left-curve, -right-curve and -stick-clamp override it")
+	stickYUp := flag.Bool("stick-y-up", false, "Gamepad output: report sticks pushed up as positive Y instead of negative")
+	bindSpec := flag.String("bind", "", "Extra codes sent along with buttons, as BUTTON=OUTPUT:CODE[+OUTPUT:CODE], comma separated (e.g. a=keyboard:key_enter)")
+	merge := flag.Bool("merge", false, "Feed every controller into a single virtual gamepad, e.g. for a helper holding a second controller")
+	mergeSticks := flag.String("merge-sticks", "last-moved", "With -merge, which stick drives the gamepad: last-moved, average or max-magnitude")
+	impersonate := flag.String("impersonate", "", "What virtual gamepads present as: procon, xbox360 or VID:PID[:NAME], as [SERIAL=]IDENTITY, comma separated")
+	printSDLMapping := flag.Bool("print-sdl-mapping", false, "Print the SDL_GAMECONTROLLERCONFIG line of the virtual gamepads and exit")
+	assignSpec := flag.String("assign", "", "Player numbers by serial taken at plug time, as SERIAL=PLAYER, comma separated")
+	slotsFile := flag.String("slots-file", "", "File keeping the player number of each controller across restarts, empty to disable (e.g. /var/lib/procon2-driver/slots)")
+	assignPolicy := flag.String("assign-policy", "fail", "When an assigned player is taken: fail (keep the usual slot) or swap (move the other controller)")
+	stuckThreshold := flag.Duration("stuck-threshold", 0, "Warn about buttons held this long without release, 0 disables it")
+	stuckMask := flag.Bool("stuck-mask", false, "Stop forwarding buttons detected as stuck until they are released")
+	sendReport := flag.String("send-report", "", "Send a raw output report, written as hex, to the first controller and exit (e.g. \"01 00 00 01 40 40 00 01 40 40 30 01\")")
+	calibrateDeadzone := flag.Int("calibrate-deadzone", procon2.DefaultCalibrationTuning.Deadzone, "Minimum stick deadzone set by calibration (raw units), raised for sticks that jitter more at rest")
+	calibrateCenterTolerance := flag.Float64("calibrate-center-tolerance", procon2.DefaultCalibrationTuning.CenterTolerance, "Warn when a calibrated center is further than this fraction of half the range from its middle (0 to disable)")
+	calibrateMargin := flag.Int("calibrate-margin", procon2.DefaultCalibrationTuning.Margin, "Raw units calibration adds beyond the measured stick extremes")
+	selfTest := flag.Bool("selftest", false, "Check the first controller end to end (init, LEDs, rumble, buttons and sticks) and print a pass/fail summary")
+	axisRange := flag.String("axis-range", "", "Virtual stick axis ranges as [AXIS=]MIN:MAX, comma separated (e.g. 0:255 for 8-bit axes, default -32768:32767)")
+	productSpec := flag.String("products", "", "Extra Nintendo product IDs to drive, in hex, comma separated (e.g. 0x20aa)")
+	productsFile := flag.String("products-file", "/etc/procon2-driver/products", "File keeping the product IDs added at runtime, one per line")
+	startupDrain := flag.Duration("startup-drain", procon2.DefaultConfig.StartupDrain, "Discard reports after init until the first full one, for at most this long")
+	stress := flag.Int("stress", 0, "Testing only: run with this many synthetic controllers, plugging and moving them around")
+	stressDuration := flag.Duration("stress-duration", 0, "Testing only: stop -stress after this long, 0 waits for CTRL+C")
+	flag.Usage = usageWithout("stress", "stress-duration")
+	flag.Parse()
 
-func NewManager(ctx *gousb.Context) *Manager {
-	return &Manager{
-		ctx:     ctx,
-		drivers: make(map[string]*ActiveDriver),
+	socd, err := procon2.ParseSOCDMode(*socdMode)
+	if err != nil {
+		log.Fatal(err)
 	}
-}
-
-// Scan looks for new devices and starts drivers for them
-func (m *Manager) Scan() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// Iterate all USB devices matching Nintendo VID
-	devs, err := m.ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
-		// Filter by VendorID
-		if desc.Vendor != gousb.ID(PROCON_VENDOR) {
-			return false
-		}
-
-		// Accept standard Product IDs and specific clones
-		// You can add more IDs here if needed
-		if desc.Product == 0x2009 || desc.Product == 0x2019 || desc.Product == 0x2069 {
-			return true
-		}
-		return false
-	})
-
+	turbo, err := procon2.ParseTurboRules(*turboSpec)
 	if err != nil {
-		log.Printf("Error scanning USB: %v", err)
-		return
+		log.Fatal(err)
 	}
-
-	for _, dev := range devs {
-		bus := dev.Desc.Bus
-		addr := dev.Desc.Address
-		uid := fmt.Sprintf("%d-%d", bus, addr)
-
-		// Check if we already manage this device
-		if _, exists := m.drivers[uid]; exists {
-			dev.Close() // Already running, close this duplicate handle
-			continue
-		}
-
-		// Found a new device! Find a slot.
-		slot := m.findFreeSlot()
-		if slot == -1 {
-			log.Printf("⚠️ Found device at %s but all %d player slots are full.", uid, MaxPlayers)
-			dev.Close()
-			continue
-		}
-
-		log.Printf("✨ New Controller found: %s -> Assigning Player %d", uid, slot+1)
-
-		// Start the driver
-		ad, err := m.startDriver(dev, slot, uid)
-		if err != nil {
-			log.Printf("❌ Failed to start driver for %s: %v", uid, err)
-			dev.Close()
-			m.slots[slot] = false
-		} else {
-			m.drivers[uid] = ad
-		}
+	flick, err := procon2.ParseFlickRules(*flickSpec)
+	if err != nil {
+		log.Fatal(err)
 	}
-}
-
-func (m *Manager) findFreeSlot() int {
-	for i := 0; i < MaxPlayers; i++ {
-		if !m.slots[i] {
-			m.slots[i] = true
-			return i
-		}
+	home, err := procon2.ParseHomeMapping(*homeMapping)
+	if err != nil {
+		log.Fatal(err)
 	}
-	return -1
-}
-
-func (m *Manager) startDriver(dev *gousb.Device, slotIndex int, uid string) (*ActiveDriver, error) {
-	// 1. Initialize Controller (USB)
-	ctrl, err := NewController(dev, 1, 1) // Config 1, Interface 1
+	dpad, err := procon2.ParseDpadMode(*dpadMode)
 	if err != nil {
-		return nil, err
+		log.Fatal(err)
 	}
-
-	// 2. Exclusive Grab of original evdev node to hide it
-	var grabFile *os.File
-	evdevPath, err := GetEvdevForUSB(int(dev.Desc.Bus), int(dev.Desc.Address))
-	if err == nil {
-		f, err := os.OpenFile(evdevPath, os.O_RDONLY, 0)
-		if err == nil {
-			if err := ioctl(f.Fd(), EVIOCGRAB, 1); err == nil {
-				grabFile = f
-				log.Printf("🔒 Grabbed original evdev: %s", evdevPath)
-			} else {
-				f.Close()
-			}
-		}
-	} else {
-		log.Printf("Note: Could not find evdev to grab: %v", err)
+	hatSOCD, err := procon2.ParseHatSOCDMode(*hatSOCDMode)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	// 3. Send Init Sequence
-	if err := ctrl.SendInitSequence(); err != nil {
-		ctrl.Close()
-		return nil, fmt.Errorf("init failed: %w", err)
+	leftCurve, err := parseCurveFlag(*leftCurveSpec, *curveSpec)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	// 4. Set LEDs (Player Number)
-	// We wait a moment after init before setting LEDs
-	time.Sleep(100 * time.Millisecond)
-	ctrl.SetPlayerLEDs(slotIndex + 1)
-
-	// 5. Setup HID Reader
-	if ctrl.GetHIDPath() == "" {
-		ctrl.Close()
-		return nil, fmt.Errorf("no HID path found")
+	rightCurve, err := parseCurveFlag(*rightCurveSpec, *curveSpec)
+	if err != nil {
+		log.Fatal(err)
 	}
-	reader, err := NewHIDReader(ctrl.GetHIDPath(), DefaultCalibration)
+	axes, err := procon2.ParseAxisRanges(*axisRange)
 	if err != nil {
-		ctrl.Close()
-		return nil, err
+		log.Fatal(err)
 	}
-
-	// 6. Setup Virtual Gamepad (uinput)
-	virtual, err := NewVirtualGamepad(slotIndex + 1)
+	usbIface, err := procon2.ParseUSBInterface(*usbInterface)
 	if err != nil {
-		reader.Close()
-		ctrl.Close()
-		return nil, err
+		log.Fatal(err)
 	}
-
-	d := &Driver{
-		controller: ctrl,
-		reader:     reader,
-		virtual:    virtual,
+	leds, err := procon2.ParsePlayerLEDs(*playerLEDs)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	ad := &ActiveDriver{
-		Driver:    d,
-		USBDevice: dev,
-		Slot:      slotIndex,
-		UniqueID:  uid,
-		StopChan:  make(chan struct{}),
-		GrabFile:  grabFile,
+	outputs, serialOutputs, err := procon2.ParseOutputSpec(*outputSpec)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	ad.WG.Add(1)
-	go func() {
-		defer ad.WG.Done()
-		m.driverLoop(ad)
-	}()
-
-	return ad, nil
-}
-
-func (m *Manager) driverLoop(ad *ActiveDriver) {
-	log.Printf("🎮 Player %d connected and running", ad.Slot+1)
-
-	defer func() {
-		log.Printf("🔌 Player %d (%s) disconnected", ad.Slot+1, ad.UniqueID)
-
-		// Cleanup resources
-		if ad.GrabFile != nil {
-			ioctl(ad.GrabFile.Fd(), EVIOCGRAB, 0)
-			ad.GrabFile.Close()
+	bindings, err := procon2.ParseBindings(*bindSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, kind := range []procon2.OutputKind{procon2.OutputGamepad, procon2.OutputKeyboard} {
+		used := slices.Contains(outputs, kind)
+		for _, kinds := range serialOutputs {
+			used = used || slices.Contains(kinds, kind)
 		}
-		ad.Driver.Close()
-		// ad.USBDevice is closed by ad.Driver.Close() implicitly or manually here
-		ad.USBDevice.Close()
-
-		m.mu.Lock()
-		delete(m.drivers, ad.UniqueID)
-		m.slots[ad.Slot] = false
-		m.mu.Unlock()
-	}()
-
-	ticker := time.NewTicker(2 * time.Millisecond)
-	defer ticker.Stop()
-
-	failCount := 0
-
-	for {
-		select {
-		case <-ad.StopChan:
-			return
-		case <-ticker.C:
-			state, err := ad.Driver.reader.ReadStateTimeout(100 * time.Millisecond)
-			if err != nil {
-				failCount++
-				if failCount > 20 { // ~2 seconds of failure
-					log.Printf("Player %d read timeout/error: %v", ad.Slot+1, err)
-					return // Exit loop, triggers cleanup
-				}
-				continue
-			}
-			failCount = 0
-			ad.Driver.virtual.Update(state)
+		if bindings.Uses(kind) && !used {
+			log.Fatalf("-bind sends %s codes but -outputs has no %s", kind, kind)
 		}
 	}
-}
-
-func (m *Manager) Cleanup() {
-	m.mu.Lock()
-	drivers := make([]*ActiveDriver, 0, len(m.drivers))
-	for _, ad := range m.drivers {
-		drivers = append(drivers, ad)
-		close(ad.StopChan)
-	}
-	m.mu.Unlock()
-
-	for _, ad := range drivers {
-		ad.WG.Wait()
-	}
-}
-
-// Driver struct wrapper
-type Driver struct {
-	controller *Controller
-	reader     *HIDReader
-	virtual    *VirtualGamepad
-}
-
-func (d *Driver) Close() {
-	if d.virtual != nil {
-		d.virtual.Close()
-	}
-	if d.reader != nil {
-		d.reader.Close()
+	if *evdevPath != "" && *hidrawPath == "" {
+		log.Fatal("-evdev needs -hidraw")
 	}
-	if d.controller != nil {
-		d.controller.Close()
+	stickClamp, err := procon2.ParseStickClamp(*stickClampName)
+	if err != nil {
+		log.Fatal(err)
 	}
-}
-
-// NewVirtualGamepad creates a new virtual gamepad with Player Number in name
-func NewVirtualGamepad(playerNum int) (*VirtualGamepad, error) {
-	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	mergePolicy, err := procon2.ParseStickPolicy(*mergeSticks)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open /dev/uinput: %w", err)
+		log.Fatal(err)
 	}
-
-	// Basic Setup (Keys, Axes, etc) - Same as original
-	ioctl(f.Fd(), uiSetEvBit, uintptr(evKey))
-	ioctl(f.Fd(), uiSetEvBit, uintptr(evAbs))
-	ioctl(f.Fd(), uiSetEvBit, uintptr(evSyn))
-
-	buttons := []uint16{
-		btnSouth, btnEast, btnNorth, btnWest,
-		btnTL, btnTR, btnTL2, btnTR2,
-		btnSelect, btnStart, btnMode,
-		btnThumbL, btnThumbR,
-		btnDpadUp, btnDpadDown, btnDpadLeft, btnDpadRight,
+	var preset *procon2.JoystickProcessing
+	if *presetName != "" {
+		p, err := procon2.ParseJoystickPreset(*presetName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		preset = &p
 	}
-	for _, btn := range buttons {
-		ioctl(f.Fd(), uiSetKeyBit, uintptr(btn))
+	identities, err := procon2.ParseIdentities(*impersonate)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	axes := []uint16{absX, absY, absRX, absRY}
-	for _, ax := range axes {
-		ioctl(f.Fd(), uiSetAbsBit, uintptr(ax))
+	products, err := procon2.ParseProductIDs(*productSpec)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	// Device Setup with Naming
-	var usetup uinputSetup
-	name := fmt.Sprintf("%s (Player %d)", DRIVER_NAME, playerNum)
-	copy(usetup.name[:], name)
-	usetup.id.bustype = busUsb
-	usetup.id.vendor = PROCON_VENDOR
-	usetup.id.product = 0x2019
-	usetup.id.version = 1
-
-	if err := ioctlSetup(f.Fd(), uiDevSetup, unsafe.Pointer(&usetup)); err != nil {
-		f.Close()
-		return nil, fmt.Errorf("UI_DEV_SETUP failed: %w", err)
+	assignments, err := procon2.ParseAssignments(*assignSpec)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	// Axis Setup
-	for _, ax := range axes {
-		absSetup := uinputAbsSetup{
-			code: ax,
-			info: inputAbsinfo{
-				min: -32768, max: 32767, fuzz: 16, flat: 128,
-			},
+	policy, err := procon2.ParseAssignPolicy(*assignPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	match := procon2.IsProController
+	if *matchClass {
+		match = procon2.IsNintendoGamepad
+	}
+	// The single controller modes also accept the extra product IDs
+	if saved, err := procon2.LoadProductIDs(*productsFile); err != nil {
+		log.Printf("⚠️ Ignoring product IDs file: %v", err)
+	} else if extra := slices.Concat(products, saved); len(extra) > 0 {
+		known := match
+		match = func(desc *gousb.DeviceDesc) bool {
+			if known(desc) {
+				return true
+			}
+			return desc.Vendor == procon2.PROCON_VENDOR && slices.Contains(extra, desc.Product)
 		}
-		ioctlSetup(f.Fd(), uiAbsSetup, unsafe.Pointer(&absSetup))
-	}
-
-	if err := ioctl(f.Fd(), uiDevCreate, 0); err != nil {
-		f.Close()
-		return nil, fmt.Errorf("UI_DEV_CREATE failed: %w", err)
 	}
 
-	return &VirtualGamepad{file: f, deadzone: 0.05}, nil
-}
-
-func main() {
-	daemonMode := flag.Bool("daemon", false, "Run as daemon (stderr log)")
-	calibrateMode := flag.Bool("calibrate", false, "Run calibration mode")
-	flag.Parse()
-
 	if *daemonMode {
 		log.SetOutput(os.Stderr)
 		log.SetFlags(0)
@@ -346,60 +213,93 @@ func main() {
 		log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 	}
 
-	// Calibration Mode
-	if *calibrateMode {
-		log.Println("🎮 Calibration Mode")
-		log.Println("Plug in ONE controller to calibrate")
+	initOpts := procon2.InitOptions{MaxFailRatio: *initFailRatio, PacketDelay: *initPacketDelay, Verify: *initVerify}
+	tuning := procon2.CalibrationTuning{Deadzone: *calibrateDeadzone, Margin: *calibrateMargin, CenterTolerance: *calibrateCenterTolerance}
 
-		ctx := gousb.NewContext()
-		defer ctx.Close()
+	// Non-interactive calibration, for provisioning scripts
+	if *calibrateAll {
+		os.Exit(runCalibrateAll(match, usbIface, initOpts, *initDelay, *calibrationDir,
+			procon2.QuickCalibrateOptions{Center: *calibrateCenter, Range: *calibrateRange, Tuning: tuning}))
+	}
+	if *calibrateAuto {
+		os.Exit(runAutoCalibration(match, usbIface, initOpts, *initDelay, *calibrationDir,
+			procon2.QuickCalibrateOptions{Center: *calibrateCenter, Range: *calibrateRange, Tuning: tuning}))
+	}
 
-		// Find first Pro Controller
-		devs, err := ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
-			if desc.Vendor != gousb.ID(PROCON_VENDOR) {
-				return false
-			}
-			if desc.Product == 0x2009 || desc.Product == 0x2019 || desc.Product == 0x2069 {
-				return true
-			}
-			return false
-		})
+	if *release != "" {
+		os.Exit(runRelease(match, usbIface, *release))
+	}
 
-		if err != nil || len(devs) == 0 {
-			log.Fatal("No Pro Controller found. Please connect one.")
+	// Health check, for supervisors
+	if *healthcheck {
+		if err := procon2.CheckHeartbeat(*heartbeatFile, *healthMaxAge); err != nil {
+			fmt.Fprintln(os.Stderr, "unhealthy:", err)
+			os.Exit(1)
 		}
+		fmt.Println("healthy")
+		return
+	}
 
-		dev := devs[0]
-		defer dev.Close()
+	// Self-test, for QA and "my controller doesn't work" reports
+	if *selfTest {
+		log.Println("🩺 Self-test")
+		os.Exit(runSelfTest(match, usbIface, initOpts, *initDelay, *noRumble))
+	}
 
-		// Close other devices
-		for i := 1; i < len(devs); i++ {
-			devs[i].Close()
+	// Raw output report, for probing undocumented features
+	if *sendReport != "" {
+		report, err := procon2.ParseHexReport(*sendReport)
+		if err != nil {
+			log.Fatal(err)
 		}
-
-		// Initialize controller
-		ctrl, err := NewController(dev, 1, 1)
+		sess, err := openSession(match, usbIface, initOpts, *initDelay)
 		if err != nil {
-			log.Fatal("Failed to initialize controller:", err)
+			log.Fatal(err)
 		}
-		defer ctrl.Close()
+		defer sess.Close()
 
-		if err := ctrl.SendInitSequence(); err != nil {
-			log.Fatal("Failed to send init sequence:", err)
+		if err := sess.ctrl.SendRawReport(report); err != nil {
+			log.Fatal("Sending report failed: ", err)
 		}
+		log.Printf("📤 Sent % x to %s", report, sess.serial)
+		return
+	}
 
-		time.Sleep(200 * time.Millisecond)
+	// Button test, for bug reports
+	if *buttonTest {
+		log.Println("🎮 Button Test")
+		sess, err := openSession(match, usbIface, initOpts, *initDelay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer sess.Close()
 
-		if ctrl.GetHIDPath() == "" {
-			log.Fatal("Could not find HID path for controller")
+		var buttons []procon2.Button
+		for _, b := range procon2.AllButtons {
+			if (b == procon2.ButtonGL || b == procon2.ButtonGR) && !sess.ctrl.Capabilities().Paddles {
+				continue
+			}
+			buttons = append(buttons, b)
+		}
+		results, err := procon2.RunButtonTest(sess.reader, buttons, 10*time.Second)
+		if err != nil {
+			log.Fatal("Button test failed:", err)
 		}
+		procon2.PrintButtonTest(results)
+		return
+	}
+
+	// Calibration Mode
+	if *calibrateMode {
+		log.Println("🎮 Calibration Mode")
+		log.Println("Plug in ONE controller to calibrate")
 
-		// Open reader with default calibration first
-		reader, err := NewHIDReader(ctrl.GetHIDPath(), DefaultCalibration)
+		sess, err := openSession(match, usbIface, initOpts, *initDelay)
 		if err != nil {
-			log.Fatal("Failed to open HID reader:", err)
+			log.Fatal(err)
 		}
-		defer reader.Close()
+		defer sess.Close()
+		reader := sess.reader
 
 		// Run quick calibration
 		log.Println("\n📊 Step 1: Keep both sticks centered for 2 seconds...")
@@ -408,7 +308,9 @@ func main() {
 		log.Println("Step 2: Rotate both sticks in full circles for 5 seconds...")
 		time.Sleep(1 * time.Second)
 
-		newCal, err := QuickCalibrate(reader)
+		opts := procon2.DefaultQuickCalibrateOptions
+		opts.Tuning = tuning
+		newCal, err := procon2.QuickCalibrateWithOptions(reader, opts)
 		if err != nil {
 			log.Fatal("Calibration failed:", err)
 		}
@@ -422,14 +324,23 @@ func main() {
 	LYCenter: %d, LYMin: %d, LYMax: %d,
 	RXCenter: %d, RXMin: %d, RXMax: %d,
 	RYCenter: %d, RYMin: %d, RYMax: %d,
-	Deadzone: %d,
+	Deadzone: %d, LDeadzone: %d, RDeadzone: %d,
+	LXTrim: %d, LYTrim: %d, RXTrim: %d, RYTrim: %d,
 }
 `, newCal.LXCenter, newCal.LXMin, newCal.LXMax,
 			newCal.LYCenter, newCal.LYMin, newCal.LYMax,
 			newCal.RXCenter, newCal.RXMin, newCal.RXMax,
 			newCal.RYCenter, newCal.RYMin, newCal.RYMax,
-			newCal.Deadzone)
+			newCal.Deadzone, newCal.LDeadzone, newCal.RDeadzone,
+			newCal.LXTrim, newCal.LYTrim, newCal.RXTrim, newCal.RYTrim)
+
+		return
+	}
 
+	if *printSDLMapping {
+		opts := procon2.DefaultGamepadOptions
+		opts.Home, opts.Dpad, opts.Bindings, opts.YUp = home, dpad, bindings, *stickYUp
+		printSDLMappings(opts, identities)
 		return
 	}
 
@@ -441,175 +352,160 @@ func main() {
 	defer ctx.Close()
 
 	// Initialize Manager
-	manager := NewManager(ctx)
+	cfg := procon2.DefaultConfig
+	cfg.MatchClass = *matchClass
+	cfg.ExtraProducts = products
+	cfg.ProductsFile = *productsFile
+	cfg.SlotsFile = *slotsFile
+	cfg.HeartbeatFile = *heartbeatFile
+	cfg.USBInterface = usbIface
+	cfg.InitFailRatio = *initFailRatio
+	cfg.InitDelay = *initDelay
+	cfg.InitPacketDelay = *initPacketDelay
+	cfg.InitVerify = *initVerify
+	cfg.StallTimeout = *stallTimeout
+	cfg.StallReinit = *stallReinit
+	cfg.IdleTimeout = *idleTimeout
+	cfg.NoRumble = *noRumble
+	cfg.TraceUSB = traceUSB
+	cfg.StickClamp = stickClamp
+	cfg.Merge = *merge
+	cfg.MergePolicy = mergePolicy
+	cfg.HidrawPath = *hidrawPath
+	cfg.EvdevPath = *evdevPath
+	cfg.StartupDrain = *startupDrain
+	if len(leds) > 0 {
+		cfg.PlayerLEDs = leds
+	}
+	if *antiDrift {
+		cfg.Drift = procon2.DefaultDriftOptions
+		cfg.Drift.MaxCorrection = *antiDriftMax
+	}
+	cfg.Stuck = procon2.StuckOptions{Threshold: *stuckThreshold, Mask: *stuckMask}
+	cfg.SOCD = socd
+	cfg.ReconnectGrace = *reconnectGrace
+	cfg.SlotHold = *slotHold
+	cfg.Turbo = turbo
+	cfg.Flick = flick
+	cfg.FlickOptions = procon2.FlickOptions{
+		Threshold:      *flickThreshold,
+		Cooldown:       *flickCooldown,
+		SuppressAnalog: *flickOnly,
+	}
+	cfg.Gamepad.Home = home
+	cfg.Gamepad.Dpad = dpad
+	cfg.Gamepad.HatSOCD = hatSOCD
+	cfg.Gamepad.Deadzone = gamepadDeadzone(*stickDeadzone)
+	cfg.Gamepad.LeftCurve = leftCurve
+	cfg.Gamepad.RightCurve = rightCurve
+	if preset != nil {
+		preset.Apply(&cfg)
+		explicit := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+		if explicit["stick-deadzone"] {
+			cfg.Gamepad.Deadzone = gamepadDeadzone(*stickDeadzone)
+		}
+		if explicit["curve"] || explicit["left-curve"] {
+			cfg.Gamepad.LeftCurve = leftCurve
+		}
+		if explicit["curve"] || explicit["right-curve"] {
+			cfg.Gamepad.RightCurve = rightCurve
+		}
+		if explicit["stick-clamp"] {
+			cfg.StickClamp = stickClamp
+		}
+	}
+	cfg.Gamepad.YUp = *stickYUp
+	cfg.Gamepad.Axes = axes
+	cfg.Outputs = outputs
+	cfg.SerialOutputs = serialOutputs
+	cfg.Bindings = bindings
+	cfg.Identities = identities
+	cfg.DryRun = *dryRun
+	cfg.CalibrationDir = *calibrationDir
+	if cal, ok, err := procon2.CalibrationFromEnv(); err != nil {
+		log.Fatal(err)
+	} else if ok {
+		log.Printf("📐 Using the calibration from %s for controllers without a calibration file", procon2.CalibrationEnv)
+		cfg.Calibration = &cal
+	}
+	cfg.Mouse.Speed = *mouseSpeed
+	cfg.Mouse.InvertY = *mouseInvertY
+	cfg.Mouse.NaturalScroll = *naturalScroll
+	cfg.Assignments = assignments
+	cfg.AssignPolicy = policy
+	manager := procon2.NewManager(ctx, cfg)
+	if *stress > 0 {
+		os.Exit(runStress(manager, *stress, *stressDuration))
+	}
 
 	// Signal Handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// Scanning Loop
-	go func() {
-		for {
-			manager.Scan()
-			time.Sleep(2 * time.Second)
-		}
-	}()
+	runCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	log.Println("✅ Service Ready. Waiting for controllers...")
-	<-sigChan
-	log.Println("\n🛑 Shutdown signal received. Cleaning up...")
-	manager.Cleanup()
+	// Run blocks until a shutdown signal arrives and cleans up before returning
+	manager.Run(runCtx)
 	log.Println("👋 Done.")
 }
 
-// --- UInput Constants (Must be present in main.go) ---
-const (
-	uiSetEvBit   = 0x40045564
-	uiSetKeyBit  = 0x40045565
-	uiSetAbsBit  = 0x40045567
-	uiDevSetup   = 0x405c5503
-	uiDevCreate  = 0x5501
-	uiDevDestroy = 0x5502
-	uiAbsSetup   = 0x401c5504
-
-	evSyn = 0x00
-	evKey = 0x01
-	evAbs = 0x03
-
-	btnSouth     = 0x130
-	btnEast      = 0x131
-	btnNorth     = 0x133
-	btnWest      = 0x134
-	btnTL        = 0x136
-	btnTR        = 0x137
-	btnTL2       = 0x138
-	btnTR2       = 0x139
-	btnSelect    = 0x13a
-	btnStart     = 0x13b
-	btnMode      = 0x13c
-	btnThumbL    = 0x13d
-	btnThumbR    = 0x13e
-	btnDpadUp    = 0x220
-	btnDpadDown  = 0x221
-	btnDpadLeft  = 0x222
-	btnDpadRight = 0x223
-
-	absX   = 0x00
-	absY   = 0x01
-	absRX  = 0x03
-	absRY  = 0x04
-	busUsb = 0x03
-)
-
-type VirtualGamepad struct {
-	file      *os.File
-	lastState ControllerState
-	deadzone  float64
-}
-
-func (v *VirtualGamepad) Update(state ControllerState) error {
-	v.sendButton(btnSouth, state.A)
-	v.sendButton(btnEast, state.B)
-	v.sendButton(btnNorth, state.X)
-	v.sendButton(btnWest, state.Y)
-	v.sendButton(btnTL, state.L)
-	v.sendButton(btnTR, state.R)
-	v.sendButton(btnTL2, state.ZL)
-	v.sendButton(btnTR2, state.ZR)
-	v.sendButton(btnDpadUp, state.DpadUp)
-	v.sendButton(btnDpadDown, state.DpadDown)
-	v.sendButton(btnDpadLeft, state.DpadLeft)
-	v.sendButton(btnDpadRight, state.DpadRight)
-	v.sendButton(btnStart, state.Plus)
-	v.sendButton(btnSelect, state.Minus)
-	v.sendButton(btnMode, state.Home)
-	v.sendButton(btnThumbL, state.LStickPress)
-	v.sendButton(btnThumbR, state.RStickPress)
-
-	lx := v.applyDeadzone(state.Joysticks.LX)
-	ly := v.applyDeadzone(-state.Joysticks.LY)
-	rx := v.applyDeadzone(state.Joysticks.RX)
-	ry := v.applyDeadzone(-state.Joysticks.RY)
-
-	v.sendAxis(absX, int32(lx*32767))
-	v.sendAxis(absY, int32(ly*32767))
-	v.sendAxis(absRX, int32(rx*32767))
-	v.sendAxis(absRY, int32(ry*32767))
-
-	v.sendSync()
-	v.lastState = state
-	return nil
-}
-
-func (v *VirtualGamepad) sendButton(code uint16, pressed bool) {
-	val := int32(0)
-	if pressed {
-		val = 1
+// parseCurveFlag parses a response curve flag, or fallback when it is empty.
+// A value starting with @ names a file to load.
+func parseCurveFlag(spec, fallback string) (procon2.ResponseCurve, error) {
+	if spec == "" {
+		spec = fallback
 	}
-	v.writeEvent(evKey, code, val)
-}
-func (v *VirtualGamepad) sendAxis(code uint16, value int32) {
-	v.writeEvent(evAbs, code, value)
-}
-func (v *VirtualGamepad) sendSync() {
-	v.writeEvent(evSyn, 0, 0)
-}
-func (v *VirtualGamepad) writeEvent(typ, code uint16, value int32) {
-	var tv syscall.Timeval
-	syscall.Gettimeofday(&tv)
-	event := inputEvent{time: tv, typ: typ, code: code, value: value}
-	syscall.Write(int(v.file.Fd()), (*(*[unsafe.Sizeof(event)]byte)(unsafe.Pointer(&event)))[:])
-}
-func (v *VirtualGamepad) applyDeadzone(value float64) float64 {
-	if value > -v.deadzone && value < v.deadzone {
-		return 0.0
+	if spec == "" {
+		return nil, nil
 	}
-	return value
-}
-func (v *VirtualGamepad) Close() error {
-	if v.file != nil {
-		ioctl(v.file.Fd(), uiDevDestroy, 0)
-		return v.file.Close()
+	if path, ok := strings.CutPrefix(spec, "@"); ok {
+		return procon2.LoadResponseCurve(path)
 	}
-	return nil
+	return procon2.ParseResponseCurve(spec)
 }
 
-// UInput Structs
-type inputEvent struct {
-	time      syscall.Timeval
-	typ, code uint16
-	value     int32
-}
-type inputId struct {
-	bustype, vendor, product, version uint16
-}
-type inputAbsinfo struct {
-	value, min, max, fuzz, flat, resolution int32
-}
-type uinputAbsSetup struct {
-	code uint16
-	_    [2]byte
-	info inputAbsinfo
-	_    [4]byte
-}
-type uinputSetup struct {
-	id           inputId
-	name         [80]byte
-	ffEffectsMax uint32
-	absinfo      [0x40]uinputAbsSetup
+// gamepadDeadzone turns the -stick-deadzone value into GamepadOptions.Deadzone, where
+// zero means the default
+func gamepadDeadzone(v float64) float64 {
+	if v == 0 {
+		return procon2.NoDeadzone
+	}
+	return v
 }
 
-func ioctl(fd uintptr, request uintptr, arg uintptr) error {
-	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
-	if errno != 0 {
-		return errno
+// usageWithout is the default usage message, leaving out the given testing flags
+func usageWithout(hidden ...string) func() {
+	return func() {
+		visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		visible.SetOutput(flag.CommandLine.Output())
+		flag.VisitAll(func(f *flag.Flag) {
+			if !slices.Contains(hidden, f.Name) {
+				visible.Var(f.Value, f.Name, f.Usage)
+				visible.Lookup(f.Name).DefValue = f.DefValue
+			}
+		})
+		fmt.Fprintf(visible.Output(), "Usage of %s:\n", os.Args[0])
+		visible.PrintDefaults()
 	}
-	return nil
 }
-func ioctlSetup(fd uintptr, request uintptr, arg unsafe.Pointer) error {
-	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
-	if errno != 0 {
-		return errno
+
+// printSDLMappings prints the SDL mapping of the gamepads of each identity in identities,
+// or of the default one when there is none
+func printSDLMappings(opts procon2.GamepadOptions, identities map[string]procon2.VirtualIdentity) {
+	if len(identities) == 0 {
+		fmt.Println(opts.SDLMapping())
+		return
+	}
+	serials := make([]string, 0, len(identities))
+	for serial := range identities {
+		serials = append(serials, serial)
+	}
+	slices.Sort(serials)
+	for _, serial := range serials {
+		opts.Identity = identities[serial]
+		if serial != "" {
+			fmt.Printf("# %s\n", serial)
+		}
+		fmt.Println(opts.SDLMapping())
 	}
-	return nil
 }
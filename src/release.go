@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/dalmatheo/procon2-driver/procon2"
+	"github.com/google/gousb"
+)
+
+// runRelease hands the controller whose serial or BUS-ADDR unique ID is target back to
+// the kernel: its profile's release sequence is sent, then the interface is released
+// with auto-detach on, so the kernel driver binds again. It returns the process exit code.
+func runRelease(match func(*gousb.DeviceDesc) bool, iface procon2.USBInterface, target string) int {
+	ctx := gousb.NewContext()
+	code := releaseTarget(ctx, match, iface, target)
+	if err := ctx.Close(); err != nil {
+		log.Printf("❌ Could not close the USB context: %v", err)
+		return 1
+	}
+	return code
+}
+
+// releaseTarget is runRelease on an open context, closing every device it opens
+func releaseTarget(ctx *gousb.Context, match func(*gousb.DeviceDesc) bool, iface procon2.USBInterface, target string) int {
+	devs, err := ctx.OpenDevices(match)
+	var dev *gousb.Device
+	for _, d := range devs {
+		serial, _ := d.SerialNumber()
+		uid := fmt.Sprintf("%d-%d", d.Desc.Bus, d.Desc.Address)
+		if dev == nil && (serial == target || uid == target) {
+			dev = d
+			continue
+		}
+		d.Close()
+	}
+	if dev == nil {
+		if err != nil {
+			log.Printf("❌ No controller %s: %v", target, err)
+		} else {
+			log.Printf("❌ No controller %s", target)
+		}
+		return 1
+	}
+
+	code := releaseDevice(dev, iface, target)
+	if err := dev.Close(); err != nil {
+		log.Printf("❌ Could not close %s: %v", target, err)
+		return 1
+	}
+	return code
+}
+
+// releaseDevice sends the release sequence to dev and releases its interface
+func releaseDevice(dev *gousb.Device, iface procon2.USBInterface, target string) int {
+	// Whatever kernel driver claiming the interface detaches comes back on release
+	if err := dev.SetAutoDetach(true); err != nil {
+		log.Printf("⚠️ Auto-detach unavailable, the kernel driver may need a replug: %v", err)
+	}
+	if iface.Config == 0 {
+		iface = procon2.USBInterfaceFor(dev.Desc)
+	}
+	ctrl, err := procon2.NewControllerWithOptions(dev, iface.Config, iface.Interface, procon2.ControllerOptions{NoHIDPath: true})
+	if err != nil {
+		log.Printf("❌ %v (is the driver still running for it?)", err)
+		return 1
+	}
+	ctrl.SetUSBTrace(traceUSB)
+	if err := ctrl.Release(); err != nil {
+		log.Printf("⚠️ Release incomplete: %v", err)
+	}
+	log.Printf("👋 Released %s to the kernel", target)
+	return 0
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/dalmatheo/procon2-driver/procon2"
+	"github.com/google/gousb"
+)
+
+// selfTestStep is the outcome of one -selftest step
+type selfTestStep struct {
+	name    string
+	err     error
+	skipped bool
+}
+
+// runSelfTest exercises the init sequence, LEDs, rumble and reports of the first
+// controller and prints a summary. It returns the process exit code.
+func runSelfTest(match func(*gousb.DeviceDesc) bool, iface procon2.USBInterface, initOpts procon2.InitOptions, initDelay time.Duration, noRumble bool) int {
+	var steps []selfTestStep
+	record := func(name string, err error) {
+		steps = append(steps, selfTestStep{name: name, err: err})
+		if err != nil {
+			log.Printf("❌ %s: %v", name, err)
+		} else {
+			log.Printf("✅ %s", name)
+		}
+	}
+	skip := func(name, why string) {
+		steps = append(steps, selfTestStep{name: name, skipped: true})
+		log.Printf("⏭️ %s: skipped, %s", name, why)
+	}
+
+	// Init sequence, done while opening
+	sess, err := openSession(match, iface, initOpts, initDelay)
+	record("Open and initialize", err)
+	if err != nil {
+		return printSelfTest(steps)
+	}
+	defer sess.Close()
+	log.Printf("🎮 Testing controller %s (%s)", sess.serial, sess.ctrl.Capabilities())
+
+	record("First full report", sess.reader.WaitReady(2*time.Second))
+
+	// LEDs, cycled so they can be checked by eye
+	if sess.ctrl.ReadOnly() {
+		skip("Player LEDs", "the controller has no output endpoint")
+	} else {
+		log.Println("💡 Cycling player LEDs 1 to 4, watch the controller...")
+		var ledErr error
+		for n := 1; n <= procon2.MaxPlayers && ledErr == nil; n++ {
+			ledErr = sess.ctrl.SetLEDPattern(procon2.PlayerLEDPattern(procon2.DefaultPlayerLEDs, n))
+			time.Sleep(400 * time.Millisecond)
+		}
+		record("Player LEDs", ledErr)
+	}
+
+	// Rumble
+	if !sess.ctrl.Capabilities().Rumble {
+		skip("Rumble", "the controller has no rumble")
+	} else if noRumble {
+		skip("Rumble", "rumble is turned off")
+	} else {
+		log.Println("📳 Playing a short rumble...")
+		player, err := procon2.NewHapticPlayerFor(sess.ctrl)
+		if err == nil {
+			profile := sess.ctrl.Profile()
+			err = player.PlayWithOptions(profile.TestPattern, 4*time.Millisecond, 2*time.Second, procon2.HapticOptions{Format: profile.Haptics})
+			player.Close()
+		}
+		record("Rumble", err)
+	}
+
+	// Reports, checking both buttons and sticks reach us
+	log.Println("👉 Press any button and push a stick all the way within 10 seconds...")
+	record("Buttons and sticks", waitForInput(sess.reader, 10*time.Second))
+
+	return printSelfTest(steps)
+}
+
+// waitForInput waits until a button is pressed and a stick pushed far from its center
+func waitForInput(reader *procon2.HIDReader, timeout time.Duration) error {
+	var button, stick bool
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		state, err := reader.ReadStateTimeout(100 * time.Millisecond)
+		if err != nil {
+			continue
+		}
+		if !button && len(state.GetPressedButtons()) > 0 {
+			button = true
+			log.Printf("   Button seen: %v", state.GetPressedButtons())
+		}
+		j := state.Joysticks
+		if !stick && (math.Abs(j.LX) > 0.8 || math.Abs(j.LY) > 0.8 || math.Abs(j.RX) > 0.8 || math.Abs(j.RY) > 0.8) {
+			stick = true
+			log.Println("   Stick movement seen")
+		}
+		if button && stick {
+			return nil
+		}
+	}
+	switch {
+	case !button && !stick:
+		return fmt.Errorf("no button press nor stick movement seen")
+	case !button:
+		return fmt.Errorf("no button press seen")
+	}
+	return fmt.Errorf("no stick movement seen")
+}
+
+// printSelfTest prints the summary and returns 0 when no step failed, 1 otherwise
+func printSelfTest(steps []selfTestStep) int {
+	fmt.Println("\n📋 Self-test summary:")
+	failed := 0
+	for _, s := range steps {
+		switch {
+		case s.skipped:
+			fmt.Printf("   SKIP  %s\n", s.name)
+		case s.err != nil:
+			failed++
+			fmt.Printf("   FAIL  %s: %v\n", s.name, s.err)
+		default:
+			fmt.Printf("   PASS  %s\n", s.name)
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("\n❌ %d step(s) failed\n", failed)
+		return 1
+	}
+	fmt.Println("\n✅ Controller works end to end")
+	return 0
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"syscall"
+	"time"
+
+	"github.com/dalmatheo/procon2-driver/procon2"
+)
+
+// stressCleanupTimeout is how long the shutdown may take before -stress calls it stuck
+const stressCleanupTimeout = 10 * time.Second
+
+// stressInterval is how often -stress does something to the manager
+const stressInterval = 50 * time.Millisecond
+
+// runStress runs the manager with n synthetic controllers, unplugging, replugging and
+// moving them around while scanning, until duration elapsed (0 waits for a signal).
+// It returns 1 when the shutdown hangs, after dumping every goroutine, and 0 otherwise.
+func runStress(m *procon2.Manager, n int, duration time.Duration) int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+
+	serials := make([]string, n)
+	for i := range serials {
+		serials[i] = fmt.Sprintf("SYNTH-%d", i+1)
+	}
+	log.Printf("🏋️ Stress testing with %d synthetic controllers, CTRL+C to stop", n)
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var added, addFailed, unplugged, assigned, assignFailed, scans int
+	ticker := time.NewTicker(stressInterval)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+
+		running := m.Snapshot()
+		present := make(map[string]bool, len(running))
+		for _, info := range running {
+			present[info.Serial] = true
+		}
+		// Plug back one controller that is away, taking a held slot when there is one
+		for _, serial := range serials {
+			if present[serial] {
+				continue
+			}
+			if _, err := m.AddSynthetic(serial); err != nil {
+				addFailed++
+			} else {
+				added++
+			}
+			break
+		}
+
+		if len(running) == 0 {
+			continue
+		}
+		target := running[rng.Intn(len(running))]
+		switch rng.Intn(4) {
+		case 0:
+			if err := m.UnplugSynthetic(target.UniqueID); err == nil {
+				unplugged++
+			}
+		case 1:
+			if err := m.Assign(target.UniqueID, rng.Intn(procon2.MaxPlayers)+1); err != nil {
+				assignFailed++
+			} else {
+				assigned++
+			}
+		case 2:
+			m.Scan()
+			scans++
+		case 3:
+			m.SetRumble(target.UniqueID, rng.Intn(2) == 0)
+		}
+	}
+
+	log.Printf("🏋️ Plugged %d (%d refused), unplugged %d, moved %d (%d refused), scanned %d times",
+		added, addFailed, unplugged, assigned, assignFailed, scans)
+	start := time.Now()
+	select {
+	case <-done:
+		log.Printf("✅ Shutdown took %v", time.Since(start).Round(time.Millisecond))
+		return 0
+	case <-time.After(stressCleanupTimeout):
+		log.Printf("❌ Shutdown still running after %v, goroutines:", stressCleanupTimeout)
+		pprof.Lookup("goroutine").WriteTo(os.Stderr, 2)
+		return 1
+	}
+}